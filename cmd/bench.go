@@ -0,0 +1,215 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"build-your-own-curl/pkg/curl"
+
+	"github.com/spf13/cobra"
+)
+
+// benchConfig holds the settings of the bench subcommand: the load to
+// generate, and the options each of its requests is built from.
+type benchConfig struct {
+	requests    int
+	concurrency int
+	duration    time.Duration
+	opts        curl.Options
+}
+
+var bench = benchConfig{opts: curl.DefaultOptions()}
+
+var benchCmd = &cobra.Command{
+	Use:   "bench [flags] URL",
+	Short: "Load test a URL and report throughput, latency and status codes",
+	Long: `Bench sends the same request to URL over and over, from --concurrency
+workers at once that reuse their connections, until --requests have been
+made or --duration has passed, and then prints a summary.`,
+	Args: cobra.ExactArgs(1),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The config file holds options for fetching, not for bench.
+		cmd.SilenceUsage = true
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if bench.duration > 0 && !cmd.Flags().Changed("requests") {
+			bench.requests = 0
+		}
+		return runBench(cmd.Context(), &bench, args[0], os.Stdout)
+	},
+}
+
+// benchResult is the outcome of one bench request.
+type benchResult struct {
+	latency time.Duration
+	status  int   // of the final response, or 0 on error
+	err     error // why the request failed, or nil
+}
+
+// runBench runs the load test c describes against rawURL and writes its
+// summary to w.
+func runBench(ctx context.Context, c *benchConfig, rawURL string, w io.Writer) error {
+	if c.concurrency < 1 {
+		return fmt.Errorf("invalid --concurrency %d", c.concurrency)
+	}
+	if c.requests <= 0 && c.duration <= 0 {
+		return fmt.Errorf("bench needs --requests or --duration")
+	}
+	if _, err := curl.ParseURL(rawURL); err != nil {
+		return err
+	}
+	o := &c.opts
+	req := &curl.Request{URL: rawURL, Method: o.RequestMethod()}
+	if len(o.Data) > 0 {
+		data, err := o.RequestData()
+		if err != nil {
+			return err
+		}
+		req.Body = []byte(data)
+	}
+	client := &curl.Client{Options: o}
+	defer client.CloseIdleConnections()
+
+	if c.duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.duration)
+		defer cancel()
+	}
+
+	var (
+		started atomic.Int64
+		mu      sync.Mutex
+		results []benchResult
+		wg      sync.WaitGroup
+	)
+	start := time.Now()
+	for range c.concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for !over(ctx) && (c.requests == 0 || started.Add(1) <= int64(c.requests)) {
+				r := benchRequest(ctx, client, req)
+				if r.err != nil && over(ctx) {
+					// Cut short by --duration or an interrupt, so not a
+					// failure of the server.
+					break
+				}
+				mu.Lock()
+				results = append(results, r)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if len(results) == 0 && ctx.Err() != nil && c.duration == 0 {
+		return curl.ErrInterrupted
+	}
+
+	writeBenchSummary(w, c, results, time.Since(start))
+	return nil
+}
+
+// over reports whether ctx is done or, as reads and writes may fail on its
+// deadline a moment before it is marked done, past its deadline.
+func over(ctx context.Context) bool {
+	deadline, ok := ctx.Deadline()
+	return ctx.Err() != nil || (ok && !time.Now().Before(deadline))
+}
+
+// benchRequest makes one request of the load test with c and times it.
+func benchRequest(ctx context.Context, c *curl.Client, req *curl.Request) benchResult {
+	start := time.Now()
+	resp, err := c.Do(ctx, req)
+	r := benchResult{latency: time.Since(start), err: err}
+	if err == nil {
+		r.status = resp.StatusCode
+	}
+	return r
+}
+
+// writeBenchSummary writes the table of the results of a load test that
+// took elapsed to w.
+func writeBenchSummary(w io.Writer, c *benchConfig, results []benchResult, elapsed time.Duration) {
+	var latencies []time.Duration
+	statuses := make(map[int]int)
+	failures := make(map[string]int)
+	for _, r := range results {
+		if r.err != nil {
+			failures[curl.Classify(r.err).Error()]++
+			continue
+		}
+		latencies = append(latencies, r.latency)
+		statuses[r.status]++
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Requests:\t%d\t(%d failed)\n", len(results), len(results)-len(latencies))
+	fmt.Fprintf(tw, "Concurrency:\t%d\n", c.concurrency)
+	fmt.Fprintf(tw, "Duration:\t%s\n", elapsed.Round(time.Millisecond))
+	fmt.Fprintf(tw, "Requests/sec:\t%.2f\n", float64(len(results))/elapsed.Seconds())
+	if len(latencies) > 0 {
+		fmt.Fprintf(tw, "\nLatency\n")
+		for _, p := range []struct {
+			name string
+			q    float64
+		}{{"p50", 0.50}, {"p90", 0.90}, {"p99", 0.99}, {"max", 1}} {
+			fmt.Fprintf(tw, "  %s\t%s\n", p.name, percentile(latencies, p.q).Round(time.Microsecond))
+		}
+	}
+	if len(statuses) > 0 {
+		fmt.Fprintf(tw, "\nStatus codes\n")
+		codes := make([]int, 0, len(statuses))
+		for code := range statuses {
+			codes = append(codes, code)
+		}
+		sort.Ints(codes)
+		for _, code := range codes {
+			fmt.Fprintf(tw, "  %d\t%d\n", code, statuses[code])
+		}
+	}
+	if len(failures) > 0 {
+		fmt.Fprintf(tw, "\nErrors\n")
+		msgs := make([]string, 0, len(failures))
+		for msg := range failures {
+			msgs = append(msgs, msg)
+		}
+		sort.Strings(msgs)
+		for _, msg := range msgs {
+			fmt.Fprintf(tw, "  %d\t%s\n", failures[msg], msg)
+		}
+	}
+	tw.Flush()
+}
+
+// percentile returns the q-th quantile of sorted, by the nearest-rank
+// method.
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	i := int(q*float64(len(sorted))+0.5) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+func init() {
+	rootCmd.AddCommand(benchCmd)
+	// Having a subcommand would otherwise add cobra's "completion" one,
+	// which a URL could be mistaken for.
+	rootCmd.CompletionOptions.DisableDefaultCmd = true
+
+	f := benchCmd.Flags()
+	f.IntVarP(&bench.requests, "requests", "n", 200, "number of requests to make, or with --duration the most to make")
+	f.IntVarP(&bench.concurrency, "concurrency", "c", 10, "number of requests to have in flight at once")
+	f.DurationVar(&bench.duration, "duration", 0, "keep sending requests for this long, e.g. 10s, instead of a fixed number")
+	f.StringVarP(&bench.opts.Method, "request", "X", "", "HTTP method to use for the requests (default GET, or POST with -d)")
+	f.StringArrayVarP(&bench.opts.Headers, "header", "H", nil, "extra header to include in the requests, as \"Name: Value\" (repeatable)")
+	f.VarP(&dataFlag{data: &bench.opts.Data}, "data", "d", "send data in a POST request body, or the contents of @file (repeatable, joined with &)")
+	f.BoolVarP(&bench.opts.Insecure, "insecure", "k", false, "skip verification of the server's TLS certificate and hostname")
+	f.BoolVar(&bench.opts.HTTP2, "http2", false, "use HTTP/2 if the server agrees to it through ALPN on an https connection")
+}
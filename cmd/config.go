@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// cfgFile is the --config file, or "" for $HOME/.build-your-own-curl.yaml.
+var cfgFile string
+
+// noDefaultConfig is set by -q, as the first argument, to leave out
+// $HOME/.build-your-own-curl.yaml. A --config file is still read.
+var noDefaultConfig bool
+
+// loadConfig reads the config file and uses its values for every flag of
+// cmd not given on the command line. Flags given on the command line win
+// over the config file, which wins over the built-in defaults. Keys are the
+// long flag names, such as "user-agent" or "location"; repeatable flags take
+// a list.
+func loadConfig(cmd *cobra.Command) error {
+	if cfgFile == "" && noDefaultConfig {
+		return nil
+	}
+	v := viper.New()
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+	} else {
+		v.AddConfigPath("$HOME")
+		v.SetConfigType("yaml")
+		v.SetConfigName("." + progName)
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if cfgFile == "" && errors.As(err, &notFound) {
+			return nil
+		}
+		return fmt.Errorf("reading config file: %w", err)
+	}
+
+	for _, key := range v.AllKeys() {
+		f := cmd.Flags().Lookup(key)
+		if f == nil || key == "config" {
+			return fmt.Errorf("%s: unknown option %q", v.ConfigFileUsed(), key)
+		}
+		if f.Changed {
+			continue
+		}
+		if err := setFromConfig(cmd.Flags(), f, v.Get(key)); err != nil {
+			return fmt.Errorf("%s: option %q: %w", v.ConfigFileUsed(), key, err)
+		}
+	}
+	return nil
+}
+
+// setFromConfig sets flag f to value, a scalar or, for repeatable flags, a
+// list from the config file.
+func setFromConfig(fs *pflag.FlagSet, f *pflag.Flag, value any) error {
+	values, ok := value.([]any)
+	if !ok {
+		values = []any{value}
+	}
+	for _, v := range values {
+		if err := fs.Set(f.Name, fmt.Sprint(v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
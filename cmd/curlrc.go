@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"build-your-own-curl/pkg/curl"
+
+	"github.com/spf13/pflag"
+)
+
+// expandCurlrc replaces each "-K file" in args with the arguments read from
+// the curlrc file, so that its options take effect where -K was given.
+func expandCurlrc(fs *pflag.FlagSet, args []string) ([]string, error) {
+	var out []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		var name string
+		switch {
+		case arg == "--":
+			return append(out, args[i:]...), nil
+		case arg == "-K" || arg == "--curlrc":
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("flag needs an argument: %s", arg)
+			}
+			i++
+			name = args[i]
+		case strings.HasPrefix(arg, "--curlrc="):
+			name = strings.TrimPrefix(arg, "--curlrc=")
+		case strings.HasPrefix(arg, "-K") && !strings.HasPrefix(arg, "--"):
+			name = strings.TrimPrefix(arg, "-K")
+		default:
+			out = append(out, arg)
+			continue
+		}
+
+		rcArgs, err := readCurlrc(fs, name)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, rcArgs...)
+	}
+	return out, nil
+}
+
+// readCurlrc reads the curlrc file called name, or stdin for "-", into
+// command-line arguments. Each line holds one option, with or without its
+// leading dashes, and its value if it takes one, separated by spaces, "="
+// or ":". Values may be double-quoted with backslash escapes. Lines
+// starting with # are comments, and "url" gives a URL to fetch.
+func readCurlrc(fs *pflag.FlagSet, name string) ([]string, error) {
+	b, err := curl.ReadDataFile(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []string
+	for n, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		lineArgs, err := parseCurlrcLine(fs, line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, n+1, err)
+		}
+		args = append(args, lineArgs...)
+	}
+	return args, nil
+}
+
+// parseCurlrcLine turns one curlrc line into command-line arguments.
+func parseCurlrcLine(fs *pflag.FlagSet, line string) ([]string, error) {
+	end := strings.IndexAny(line, " \t=:")
+	if end < 0 {
+		end = len(line)
+	}
+	option, rest := line[:end], strings.TrimLeft(line[end:], " \t")
+	if rest != "" && (rest[0] == '=' || rest[0] == ':') {
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+
+	var f *pflag.Flag
+	switch {
+	case strings.HasPrefix(option, "--"):
+		f = fs.Lookup(option[2:])
+	case strings.HasPrefix(option, "-") && len(option) == 2:
+		f = fs.ShorthandLookup(option[1:])
+	case option == "url":
+	default:
+		option = "--" + option
+		f = fs.Lookup(option[2:])
+	}
+	if f == nil && option != "url" {
+		return nil, fmt.Errorf("unknown option %q", option)
+	}
+
+	if f != nil && f.NoOptDefVal != "" {
+		if rest != "" {
+			return nil, fmt.Errorf("option %q takes no value", option)
+		}
+		return []string{option}, nil
+	}
+	value, err := curlrcValue(rest)
+	if err != nil {
+		return nil, err
+	}
+	if option == "url" {
+		return []string{value}, nil
+	}
+	return []string{option, value}, nil
+}
+
+// curlrcValue parses an option value: a double-quoted string with \", \\,
+// \t, \n, \r and \v escapes, or anything up to the first space.
+func curlrcValue(s string) (string, error) {
+	if !strings.HasPrefix(s, `"`) {
+		if i := strings.IndexAny(s, " \t"); i >= 0 {
+			s = s[:i]
+		}
+		return s, nil
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"':
+			return b.String(), nil
+		case c == '\\' && i+1 < len(s):
+			i++
+			switch s[i] {
+			case 't':
+				b.WriteByte('\t')
+			case 'n':
+				b.WriteByte('\n')
+			case 'r':
+				b.WriteByte('\r')
+			case 'v':
+				b.WriteByte('\v')
+			default:
+				b.WriteByte(s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated quoted value %s", s)
+}
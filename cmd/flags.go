@@ -0,0 +1,36 @@
+package cmd
+
+import "build-your-own-curl/pkg/curl"
+
+// dataFlag is a flag value that adds to the request data, so that -d,
+// --data-raw and --data-urlencode values keep their order on the command
+// line.
+type dataFlag struct {
+	data *[]curl.DataArg
+	kind curl.DataKind
+}
+
+func (f *dataFlag) String() string { return "" }
+
+func (f *dataFlag) Set(s string) error {
+	*f.data = append(*f.data, curl.DataArg{Value: s, Kind: f.kind})
+	return nil
+}
+
+func (f *dataFlag) Type() string { return "data" }
+
+// formFlag is a flag value that adds a form field, so that -F and
+// --form-string fields keep their order on the command line.
+type formFlag struct {
+	forms   *[]curl.FormArg
+	literal bool
+}
+
+func (f *formFlag) String() string { return "" }
+
+func (f *formFlag) Set(s string) error {
+	*f.forms = append(*f.forms, curl.FormArg{Value: s, Literal: f.literal})
+	return nil
+}
+
+func (f *formFlag) Type() string { return "name=value" }
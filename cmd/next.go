@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+
+	"build-your-own-curl/pkg/curl"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// nextArgs holds the segments of the command line after each --next, which
+// are parsed in turn once the transfers before them are done.
+var nextArgs [][]string
+
+// defaultOpts is opts as the flag definitions left it, before any were
+// parsed. Each --next segment starts over from it.
+var defaultOpts curl.Options
+
+// splitNext splits args into the segments that --next, or -:, separates.
+// Everything after a "--" belongs to the last segment.
+func splitNext(args []string) [][]string {
+	segments := [][]string{nil}
+	for i, arg := range args {
+		last := len(segments) - 1
+		if arg == "--" {
+			segments[last] = append(segments[last], args[i:]...)
+			break
+		}
+		if arg == "--next" || arg == "-:" {
+			segments = append(segments, nil)
+			continue
+		}
+		segments[last] = append(segments[last], arg)
+	}
+	return segments
+}
+
+// runSegments fetches urls with o, which holds the options of the first
+// segment of the command line, and then the URLs of each --next segment
+// with the options of that segment, one after the other. Idle connections
+// are handed on to the next segment as long as it makes them the same way.
+// As with URLs within a segment, a failure does not stop the segments
+// after it, and the exit status is that of the last failure.
+func runSegments(ctx context.Context, cmd *cobra.Command, o *curl.Options, urls []string) error {
+	var c curl.Client
+	defer c.CloseIdleConnections()
+	first := *o
+
+	status := 0
+	for i := 0; i <= len(nextArgs); i++ {
+		if i > 0 {
+			var err error
+			if urls, err = parseNext(cmd, nextArgs[i-1], &first); err != nil {
+				status = o.Report(err)
+				break
+			}
+		}
+		if err := c.Run(ctx, o, urls); err != nil {
+			status = o.Report(err)
+			// Run reports the failures of the transfers itself, so an
+			// error with more to report means it could not start, as
+			// when the --stderr file cannot be opened.
+			if exitErr := curl.Classify(err); exitErr.Err != nil {
+				break
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if status != 0 {
+		return &curl.ExitError{Code: status}
+	}
+	return nil
+}
+
+// parseNext resets opts to the defaults and the config file and sets it
+// from args, the flags of a --next segment, returning the URLs it names.
+// The global options are kept from first, the options of the first
+// segment.
+func parseNext(cmd *cobra.Command, args []string, first *curl.Options) ([]string, error) {
+	opts = defaultOpts
+	keepGlobals(&opts, first)
+	cmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	if err := cmd.ParseFlags(args); err != nil {
+		return nil, err
+	}
+	if err := cmd.ValidateFlagGroups(); err != nil {
+		return nil, err
+	}
+	if err := loadConfig(cmd); err != nil {
+		return nil, err
+	}
+	urls := cmd.Flags().Args()
+	if len(urls) == 0 {
+		return nil, errors.New("no URL specified after --next")
+	}
+	return urls, nil
+}
+
+// keepGlobals copies to o the options of from that, as in curl, apply to
+// the whole command line rather than to one --next segment: -s, -S, -v,
+// --stderr, --trace and --trace-ascii, -Z and --parallel-max.
+func keepGlobals(o, from *curl.Options) {
+	o.Silent, o.ShowError, o.Verbose = from.Silent, from.ShowError, from.Verbose
+	o.StderrFile, o.Trace, o.TraceASCII = from.StderrFile, from.Trace, from.TraceASCII
+	o.Parallel, o.ParallelMax = from.Parallel, from.ParallelMax
+}
@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/spf13/pflag"
+)
+
+func TestParseNextKeepsGlobals(t *testing.T) {
+	noDefaultConfig = true
+	defaultOpts = opts
+	t.Cleanup(func() {
+		opts = defaultOpts
+		rootCmd.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	})
+
+	if err := rootCmd.ParseFlags([]string{"-s", "-v", "http://h/1"}); err != nil {
+		t.Fatal(err)
+	}
+	first := opts
+	urls, err := parseNext(rootCmd, []string{"-X", "PUT", "http://h/2"}, &first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(urls) != 1 || urls[0] != "http://h/2" {
+		t.Errorf("urls = %q, want [http://h/2]", urls)
+	}
+	if !opts.Silent || !opts.Verbose {
+		t.Errorf("after --next, Silent = %v and Verbose = %v, want -s and -v kept", opts.Silent, opts.Verbose)
+	}
+	if opts.Method != "PUT" {
+		t.Errorf("Method = %q, want PUT", opts.Method)
+	}
+
+	// Other options start over.
+	first = opts
+	if _, err := parseNext(rootCmd, []string{"http://h/3"}, &first); err != nil {
+		t.Fatal(err)
+	}
+	if opts.Method != "" || !opts.Silent {
+		t.Errorf("Method = %q and Silent = %v, want -X reset and -s kept", opts.Method, opts.Silent)
+	}
+}
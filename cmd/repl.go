@@ -0,0 +1,277 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"build-your-own-curl/pkg/curl"
+
+	"github.com/spf13/cobra"
+)
+
+// replOpts holds the options the repl subcommand starts from; its
+// commands change a copy of them.
+var replOpts = curl.DefaultOptions()
+
+var replCmd = &cobra.Command{
+	Use:   "repl [flags] [URL]",
+	Short: "Craft and send requests interactively",
+	Long: `Repl reads commands from stdin to build up a request, sends it with
+"send" and shows the response. Connections are reused from one send to the
+next. Type "help" for the commands.`,
+	Args: cobra.MaximumNArgs(1),
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// The config file holds options for fetching from the command
+		// line, not for the repl.
+		cmd.SilenceUsage = true
+		return nil
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		r := newRepl(replOpts)
+		defer r.client.CloseIdleConnections()
+		if len(args) > 0 {
+			r.url = args[0]
+		}
+		return r.run(cmd.Context(), os.Stdin, os.Stdout)
+	},
+}
+
+// replHelp lists the commands repl understands.
+const replHelp = `Commands:
+  url URL                 set the URL to send the request to
+  method METHOD           set the method (default GET, or POST with a body)
+  set header Name: value  add a header, replacing any of the same name
+  unset header Name       remove a header
+  body TEXT | @file       set the body, or clear it with no argument
+  show                    print the request as it would be sent
+  send                    send the request and print the response
+  save                    print the command line that sends the same request
+  help                    print this list
+  quit                    leave`
+
+// repl is the state of a repl session: the request being built up, and
+// what is kept from one send to the next.
+type repl struct {
+	opts curl.Options
+	url  string
+	body []byte // nil for no body
+
+	client *curl.Client // made with opts, which it sees change
+}
+
+func newRepl(o curl.Options) *repl {
+	r := &repl{opts: o}
+	r.client = &curl.Client{Options: &r.opts}
+	return r
+}
+
+// run reads commands from in until it ends or "quit", writing prompts and
+// results to w. A command that fails is reported, and the session goes on.
+func (r *repl) run(ctx context.Context, in io.Reader, w io.Writer) error {
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(w, "> ")
+		if !sc.Scan() {
+			fmt.Fprintln(w)
+			return sc.Err()
+		}
+		line := strings.TrimSpace(sc.Text())
+		if line == "quit" || line == "exit" {
+			return nil
+		}
+		if err := r.exec(ctx, line, w); err != nil {
+			if ctx.Err() != nil {
+				return curl.ErrInterrupted
+			}
+			exitErr := curl.Classify(err)
+			fmt.Fprintf(w, "error: (%d) %v\n", exitErr.Code, exitErr.Err)
+		}
+	}
+}
+
+// exec carries out the command line.
+func (r *repl) exec(ctx context.Context, line string, w io.Writer) error {
+	cmd, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+	switch cmd {
+	case "":
+	case "help":
+		fmt.Fprintln(w, replHelp)
+	case "url":
+		r.url = arg
+	case "method":
+		if arg != "" && !curl.ValidMethod(arg) {
+			return fmt.Errorf("invalid request method %q", arg)
+		}
+		r.opts.Method = strings.ToUpper(arg)
+	case "set", "unset":
+		what, value, _ := strings.Cut(arg, " ")
+		if what != "header" {
+			return fmt.Errorf("%s what? only \"%s header\" is known", cmd, cmd)
+		}
+		if cmd == "unset" {
+			r.removeHeader(strings.TrimSpace(value))
+			return nil
+		}
+		name, value, err := splitHeader(value)
+		if err != nil {
+			return err
+		}
+		r.removeHeader(name)
+		r.opts.Headers = append(r.opts.Headers, name+": "+value)
+	case "body":
+		return r.setBody(arg)
+	case "show":
+		req, err := r.request()
+		if err != nil {
+			return err
+		}
+		if err := r.client.WriteRequest(w, req); err != nil {
+			return err
+		}
+		if len(r.body) > 0 && r.body[len(r.body)-1] != '\n' {
+			fmt.Fprintln(w)
+		}
+	case "send":
+		return r.send(ctx, w)
+	case "save":
+		fmt.Fprintln(w, r.commandLine())
+	default:
+		return fmt.Errorf("unknown command %q; try \"help\"", cmd)
+	}
+	return nil
+}
+
+// removeHeader drops the headers called name.
+func (r *repl) removeHeader(name string) {
+	headers := r.opts.Headers[:0]
+	for _, raw := range r.opts.Headers {
+		if n, _, err := splitHeader(raw); err != nil || !strings.EqualFold(n, name) {
+			headers = append(headers, raw)
+		}
+	}
+	r.opts.Headers = headers
+}
+
+// splitHeader splits a "Name: value" header into its name and value.
+func splitHeader(s string) (name, value string, err error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return "", "", fmt.Errorf("invalid header %q: expected \"Name: Value\"", s)
+	}
+	return name, strings.TrimSpace(value), nil
+}
+
+// setBody sets the body to arg, or to the contents of the file it names
+// after an @, or clears it when arg is empty.
+func (r *repl) setBody(arg string) error {
+	switch {
+	case arg == "":
+		r.body = nil
+	case strings.HasPrefix(arg, "@"):
+		data, err := curl.ReadDataFile(arg[1:])
+		if err != nil {
+			return err
+		}
+		r.body = data
+	default:
+		r.body = []byte(arg)
+	}
+	return nil
+}
+
+// method returns the method to send: the one set, or POST when there is a
+// body and GET otherwise.
+func (r *repl) method() string {
+	if r.opts.Method != "" {
+		return r.opts.Method
+	}
+	if r.body != nil {
+		return "POST"
+	}
+	return "GET"
+}
+
+// request returns the request built up so far.
+func (r *repl) request() (*curl.Request, error) {
+	if r.url == "" {
+		return nil, fmt.Errorf("no URL set; use \"url URL\"")
+	}
+	return &curl.Request{Method: r.method(), URL: r.url, Body: r.body}, nil
+}
+
+// send sends the request and writes the header block and body of the
+// final response to w.
+func (r *repl) send(ctx context.Context, w io.Writer) error {
+	req, err := r.request()
+	if err != nil {
+		return err
+	}
+	resp, err := r.client.Do(ctx, req)
+	if err != nil {
+		return err
+	}
+	w.Write(resp.RawHeader())
+	w.Write(resp.Body)
+	if len(resp.Body) > 0 && resp.Body[len(resp.Body)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+// commandLine returns the command line that sends the request built up so
+// far, for "save".
+func (r *repl) commandLine() string {
+	args := []string{progName}
+	if r.opts.Method != "" {
+		args = append(args, "-X", r.opts.Method)
+	}
+	for _, h := range r.opts.Headers {
+		args = append(args, "-H", h)
+	}
+	if r.opts.Insecure {
+		args = append(args, "-k")
+	}
+	if r.opts.HTTP2 {
+		args = append(args, "--http2")
+	}
+	if r.opts.Location {
+		args = append(args, "-L")
+	}
+	if r.opts.Verbose {
+		args = append(args, "-v")
+	}
+	if r.body != nil {
+		args = append(args, "--data-raw", string(r.body))
+	}
+	if r.url != "" {
+		args = append(args, r.url)
+	}
+	for i, arg := range args {
+		args[i] = shellQuote(arg)
+	}
+	return strings.Join(args, " ")
+}
+
+// shellQuote quotes s for a POSIX shell, unless it is safe as it is.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func init() {
+	rootCmd.AddCommand(replCmd)
+
+	f := replCmd.Flags()
+	f.BoolVarP(&replOpts.Insecure, "insecure", "k", false, "skip verification of the server's TLS certificate and hostname")
+	f.BoolVar(&replOpts.HTTP2, "http2", false, "use HTTP/2 if the server agrees to it through ALPN on an https connection")
+	f.BoolVarP(&replOpts.Location, "location", "L", false, "follow redirects")
+	f.BoolVarP(&replOpts.Verbose, "verbose", "v", false, "print the requests, response headers and connection info to stderr")
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"build-your-own-curl/pkg/curl"
+)
+
+func TestReplSave(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     func(*curl.Options)
+		commands string
+		want     string
+	}{
+		{
+			name:     "request",
+			commands: "url http://h/a\nmethod put\nset header X-A: 1 2\nbody it's\nsave\n",
+			want:     `build-your-own-curl -X PUT -H 'X-A: 1 2' --data-raw 'it'\''s' http://h/a`,
+		},
+		{
+			name:     "flags",
+			opts:     func(o *curl.Options) { o.Insecure, o.HTTP2, o.Location, o.Verbose = true, true, true, true },
+			commands: "url http://h/\nsave\n",
+			want:     "build-your-own-curl -k --http2 -L -v http://h/",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := curl.DefaultOptions()
+			if tt.opts != nil {
+				tt.opts(&o)
+			}
+			r := newRepl(o)
+			var out strings.Builder
+			if err := r.run(context.Background(), strings.NewReader(tt.commands), &out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), "> "+tt.want+"\n") {
+				t.Errorf("save printed:\n%s\nwant the line %s", out.String(), tt.want)
+			}
+		})
+	}
+}
@@ -4,17 +4,22 @@ Copyright © 2024 NAME HERE <EMAIL ADDRESS>
 package cmd
 
 import (
-	"fmt"
-	"net"
-	"net/url"
+	"context"
 	"os"
+	"os/signal"
+	"syscall"
+
+	"build-your-own-curl/pkg/curl"
 
 	"github.com/spf13/cobra"
 )
 
+// progName is the name of the command.
+const progName = "build-your-own-curl"
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "build-your-own-curl",
+	Use:   progName,
 	Short: "A brief description of your application",
 	Long: `A longer description that spans multiple lines and likely contains
 examples and usage of using your application. For example:
@@ -22,63 +27,171 @@ examples and usage of using your application. For example:
 Cobra is a CLI library for Go that empowers applications.
 This application is a tool to generate the needed files
 to quickly create a Cobra application.`,
-	Args: cobra.ExactArgs(1),
-	// Uncomment the following line if your bare application
-	// has an action associated with it:
-	Run: func(cmd *cobra.Command, args []string) {
-		u, err := url.Parse(args[0])
-		if err != nil {
-			panic(err)
-		}
-
-		host := u.Hostname()
-		port := u.Port()
-		path := u.Path
-
-		if port == "" {
-			port = "80"
-		}
-
-		println("Host:", host)
-		println("Port:", port)
-		println("Path:", path)
-
-		conn, err := net.Dial("tcp", fmt.Sprintf("%s:%s", host, port))
-		if err != nil {
-			panic(err)
-		}
-
-		defer conn.Close()
-
-		fmt.Fprintf(conn, "GET %s HTTP/1.0\r\nHost: %s\r\n\r\n", path, host)
-
-		buf := make([]byte, 1024)
-		n, err := conn.Read(buf)
-		if err != nil {
-			panic(err)
-		}
-
-		fmt.Println(string(buf[:n]))
+	Args:          cobra.MinimumNArgs(1),
+	SilenceErrors: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// From here on errors come from the config file or the transfer,
+		// not from how the command was invoked, so usage help would only
+		// be noise.
+		cmd.SilenceUsage = true
+		return loadConfig(cmd)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return runSegments(cmd.Context(), cmd, &opts, args)
 	},
 }
 
+// opts is populated from the command-line flags.
+var opts curl.Options
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// SIGINT or SIGTERM cancels the transfers in progress, which then end with
+// the exit status of an interrupt.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Like curl, -q only counts as the very first argument.
+	noDefaultConfig = len(os.Args) > 1 && (os.Args[1] == "-q" || os.Args[1] == "--disable")
+	args, err := expandCurlrc(rootCmd.Flags(), os.Args[1:])
+	if err == nil {
+		defaultOpts = opts
+		segments := splitNext(args)
+		args, nextArgs = segments[0], segments[1:]
+		rootCmd.SetArgs(args)
+		err = rootCmd.ExecuteContext(ctx)
+	}
 	if err != nil {
-		os.Exit(1)
+		os.Exit(opts.Report(err))
 	}
 }
 
 func init() {
+	def := curl.DefaultOptions()
+
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 
-	// rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.build-your-own-curl.yaml)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file of default flag values, overridden by the command line (default is $HOME/.build-your-own-curl.yaml)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
-	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	rootCmd.Flags().StringVarP(&opts.Method, "request", "X", "", "HTTP method to use for the request (default GET, or POST with -d); CONNECT opens an interactive tunnel to the host and port of the URL")
+	rootCmd.Flags().StringArrayVarP(&opts.Headers, "header", "H", nil, "extra header to include in the request, as \"Name: Value\" (repeatable)")
+	rootCmd.Flags().VarP(&dataFlag{data: &opts.Data}, "data", "d", "send data in a POST request body, or the contents of @file with newlines removed (repeatable, joined with &)")
+	rootCmd.Flags().Var(&dataFlag{data: &opts.Data, kind: curl.DataRaw}, "data-raw", "send data like -d, but without treating a leading @ as a file name")
+	rootCmd.Flags().Var(&dataFlag{data: &opts.Data, kind: curl.DataURLEncode}, "data-urlencode", "send data like -d, URL-encoding the content part of a `value` given as content, =content, name=content, @file or name@file")
+	rootCmd.Flags().Var(&dataFlag{data: &opts.Data, kind: curl.DataJSON}, "json", "send JSON `data`, or the contents of @file, in a POST body with JSON Content-Type and Accept headers (repeatable, concatenated)")
+	rootCmd.Flags().StringVar(&opts.CACert, "cacert", "", "verify the server against the CA certificates in this PEM `file` instead of the system roots")
+	rootCmd.Flags().StringVarP(&opts.Cert, "cert", "E", "", "present the client certificate in this PEM `file`, which may also hold the key")
+	rootCmd.Flags().StringVar(&opts.Key, "key", "", "private key `file` for --cert, if not in the certificate file")
+	rootCmd.Flags().BoolVarP(&opts.AppendOutput, "append", "a", false, "append the body to the -o file instead of overwriting it")
+	rootCmd.Flags().BoolVar(&opts.CreateDirs, "create-dirs", false, "create the missing directories of the -o or -O output path")
+	rootCmd.Flags().StringVarP(&opts.Cookie, "cookie", "b", "", "send cookies from a \"name=value; name2=value2\" string, or load them from a Netscape cookie `file`")
+	rootCmd.Flags().StringVarP(&opts.CookieJar, "cookie-jar", "c", "", "write all cookies to a Netscape cookie `file` after the transfer")
+	rootCmd.Flags().BoolVarP(&opts.Netrc, "netrc", "n", false, "take credentials for each host from ~/.netrc when -u gives none")
+	rootCmd.Flags().StringVar(&opts.NetrcFile, "netrc-file", "", "like --netrc, but read credentials from this `file`")
+	rootCmd.Flags().BoolVarP(&opts.Parallel, "parallel", "Z", false, "fetch the URLs concurrently instead of one after another")
+	rootCmd.Flags().IntVar(&opts.ParallelMax, "parallel-max", def.ParallelMax, "maximum number of transfers to run at once with -Z")
+	rootCmd.Flags().StringVarP(&opts.Proxy, "proxy", "x", "", "send requests through the HTTP proxy at `[http://][user:password@]host[:port]`")
+	rootCmd.Flags().IntVar(&opts.Retry, "retry", 0, "retry up to `num` times after a transient error such as a timeout or a 429 or 5xx response")
+	rootCmd.Flags().BoolVar(&opts.RetryAllErrors, "retry-all-errors", false, "with --retry, retry after any error or HTTP error response, not just transient ones; beware that a POST or other non-idempotent request may then take effect more than once")
+	rootCmd.Flags().Float64Var(&opts.RetryDelay, "retry-delay", 0, "wait this many `seconds` between retries instead of backing off exponentially")
+	rootCmd.Flags().Float64Var(&opts.RetryMaxTime, "retry-max-time", 0, "stop retrying once this many `seconds` have passed since the first attempt")
+	rootCmd.Flags().Int64Var(&opts.SpeedLimit, "speed-limit", 0, "abort a transfer slower than this many `bytes` per second for --speed-time seconds")
+	rootCmd.Flags().IntVar(&opts.SpeedTime, "speed-time", 0, "`seconds` a transfer may stay below --speed-limit before it is aborted (default 30 with --speed-limit)")
+	rootCmd.Flags().StringVarP(&opts.ByteRange, "range", "r", "", "request only the byte `range` given, e.g. 0-499, 500-, -500 or 0-99,200-299")
+	rootCmd.Flags().StringArrayVar(&opts.ConnectTo, "connect-to", nil, "connect to CONNECT_HOST:CONNECT_PORT instead for requests to HOST:PORT, given as `HOST:PORT:CONNECT_HOST:CONNECT_PORT`; empty fields match anything or keep the original (repeatable)")
+	rootCmd.Flags().StringVarP(&opts.ContinueAt, "continue-at", "C", "", "resume a download at byte `offset`, or \"-\" to continue from the size of the output file")
+	// -q is looked for before the command line is parsed; see Execute.
+	rootCmd.Flags().BoolP("next", ":", false, "start a new set of options and URLs, fetched after the ones before it (repeatable)")
+	rootCmd.Flags().BoolP("disable", "q", false, "as the first argument, don't read the default config file")
+	// -K is expanded before the command line is parsed; see expandCurlrc.
+	rootCmd.Flags().StringArrayP("curlrc", "K", nil, "read command-line options from a curlrc `file`, one per line, where -K is given (repeatable)")
+	rootCmd.Flags().BoolVar(&opts.Digest, "digest", false, "use HTTP Digest authentication with the -u credentials")
+	rootCmd.Flags().BoolVar(&opts.Compressed, "compressed", false, "request a compressed response and decompress it")
+	rootCmd.Flags().BoolVar(&opts.Raw, "raw", false, "write the body as it came over the wire, chunked framing and content coding included; -i then shows the framed body")
+	rootCmd.Flags().Float64Var(&opts.ConnectTimeout, "connect-timeout", 0, "maximum `seconds` allowed for connecting, including the TLS handshake (fractions allowed)")
+	rootCmd.Flags().StringVarP(&opts.DumpHeader, "dump-header", "D", "", "write the response headers of every hop to `file`, or to stdout for \"-\"")
+	rootCmd.Flags().BoolVar(&opts.DumpHeaderFinal, "dump-header-final", false, "with -D, write the headers of the final response only, not those of the redirects before it")
+	rootCmd.Flags().StringVar(&opts.HeadersJSON, "output-headers-json", "", "write the headers of the final response as a JSON object to `file`, or to stdout for \"-\"")
+	rootCmd.Flags().StringVar(&opts.DNSServers, "dns-servers", "", "resolve host names with these name servers instead of the system's, given as a comma-separated `list` of IP[:port]")
+	rootCmd.Flags().Float64Var(&opts.ResolveTimeout, "resolve-timeout", 0, "maximum `seconds` allowed for resolving the host name (fractions allowed)")
+	rootCmd.Flags().StringVar(&opts.DoHURL, "doh-url", "", "resolve host names with the DNS-over-HTTPS server at this https `URL`")
+	rootCmd.Flags().Float64Var(&opts.Expect100Timeout, "expect100-timeout", def.Expect100Timeout, "`seconds` to wait for a 100 Continue before sending a large request body anyway")
+	rootCmd.Flags().BoolVarP(&opts.Fail, "fail", "f", false, "fail with exit code 22 and no output when the server returns an HTTP error")
+	rootCmd.Flags().BoolVar(&opts.FailWithBody, "fail-with-body", false, "like -f, but still write the body of the error response")
+	rootCmd.MarkFlagsMutuallyExclusive("fail", "fail-with-body")
+	rootCmd.Flags().BoolVar(&opts.HTTP2, "http2", false, "use HTTP/2 if the server agrees to it through ALPN on an https connection")
+	rootCmd.Flags().BoolVar(&opts.HTTP10, "http1.0", false, "use HTTP/1.0 instead of HTTP/1.1")
+	rootCmd.Flags().BoolVarP(&opts.Insecure, "insecure", "k", false, "skip verification of the server's TLS certificate and hostname")
+	rootCmd.Flags().StringVar(&opts.Interface, "interface", "", "make connections from the network interface with this `name`, or from this source IP address")
+	rootCmd.Flags().StringVar(&opts.LocalPort, "local-port", "", "make connections from this local `port`, or from the first free one of a low-high range")
+	rootCmd.Flags().IntVar(&opts.HappyEyeballsTimeout, "happy-eyeballs-timeout-ms", def.HappyEyeballsTimeout, "`milliseconds` to try the first address family for before racing the other against it")
+	rootCmd.Flags().BoolVarP(&opts.IPv4, "ipv4", "4", false, "resolve and connect to IPv4 addresses only")
+	rootCmd.Flags().BoolVarP(&opts.IPv6, "ipv6", "6", false, "resolve and connect to IPv6 addresses only")
+	rootCmd.MarkFlagsMutuallyExclusive("ipv4", "ipv6")
+	rootCmd.Flags().BoolVarP(&opts.Include, "include", "i", false, "include the response status line and headers in the output")
+	rootCmd.Flags().VarP(&formFlag{forms: &opts.Forms}, "form", "F", "add a multipart/form-data field given as name=value, or name=@file[;type=mime][;filename=name] to upload a file (repeatable)")
+	rootCmd.Flags().Var(&formFlag{forms: &opts.Forms, literal: true}, "form-string", "add a multipart/form-data field like -F, but send the value as it is even if it starts with @ (repeatable)")
+	rootCmd.Flags().BoolVarP(&opts.Get, "get", "G", false, "send the -d data in the URL query string of a GET request instead of in a POST body")
+	rootCmd.Flags().StringArrayVar(&opts.Queries, "query", nil, "add `name=value` to the query string of the URL, percent-encoding both (repeatable)")
+	rootCmd.Flags().BoolVarP(&opts.GlobOff, "globoff", "g", false, "don't expand [] ranges and {} lists in URLs")
+	rootCmd.Flags().BoolVarP(&opts.Head, "head", "I", false, "send a HEAD request and print only the response headers")
+	rootCmd.Flags().BoolVarP(&opts.Location, "location", "L", false, "follow redirects")
+	rootCmd.Flags().Float64VarP(&opts.MaxTime, "max-time", "m", 0, "maximum `seconds` allowed for the whole transfer, or for each attempt with --retry (fractions allowed)")
+	rootCmd.Flags().StringVar(&opts.MaxHeadersSize, "max-headers-size", def.MaxHeadersSize, "refuse a response whose header block is larger than `bytes`, with optional k, M or G suffix, or 0 for no limit")
+	rootCmd.Flags().Int64Var(&opts.HeadBytes, "head-bytes", 0, "write only the first `N` bytes of the body, after any decoding, and stop reading the rest")
+	rootCmd.Flags().StringVar(&opts.MaxFilesize, "max-filesize", "", "refuse a response whose body is larger than `bytes`, with optional k, M or G suffix")
+	rootCmd.Flags().BoolVar(&opts.LocationTrusted, "location-trusted", false, "with -L, keep sending credentials and cookies when a redirect leads to another host, which then sees them too")
+	rootCmd.Flags().BoolVar(&opts.Post301, "post301", false, "keep the method and body when -L follows a 301 redirect")
+	rootCmd.Flags().BoolVar(&opts.Post302, "post302", false, "keep the method and body when -L follows a 302 redirect")
+	rootCmd.Flags().BoolVar(&opts.Post303, "post303", false, "keep the method and body when -L follows a 303 redirect")
+	rootCmd.Flags().IntVar(&opts.MaxRedirs, "max-redirs", def.MaxRedirs, "maximum number of redirects to follow with -L, or -1 for no limit")
+	rootCmd.Flags().StringVar(&opts.LimitRate, "limit-rate", "", "maximum download `speed` in bytes per second, with optional k, M or G suffix")
+	rootCmd.Flags().BoolVar(&opts.DryRun, "dry-run", false, "print the request that would be sent, body included, without connecting")
+	rootCmd.Flags().BoolVarP(&opts.NoBuffer, "no-buffer", "N", false, "write the output as it arrives instead of once the transfer is complete, without a progress meter")
+	rootCmd.Flags().BoolVar(&opts.IgnoreContentLength, "ignore-content-length", false, "ignore the Content-Length of the response and read its body until the server closes the connection")
+	rootCmd.Flags().BoolVar(&opts.NoChunked, "no-chunked", false, "read -T - into memory to send it with a Content-Length, instead of streaming it in chunked encoding")
+	rootCmd.Flags().BoolVar(&opts.NoKeepalive, "no-keepalive", false, "open a new connection for every request instead of reusing one to the same host, and turn off TCP keepalive")
+	rootCmd.Flags().IntVar(&opts.KeepaliveTime, "keepalive-time", def.KeepaliveTime, "`seconds` a connection may be idle before TCP keepalive probes are sent")
+	rootCmd.Flags().StringArrayVarP(&opts.Output, "output", "o", nil, "write the response body to `file` instead of stdout (repeatable, one per URL)")
+	rootCmd.Flags().StringVar(&opts.EtagSave, "etag-save", "", "save the ETag of a successful download to `file`")
+	rootCmd.Flags().StringVar(&opts.EtagCompare, "etag-compare", "", "send the ETag saved in `file` as If-None-Match, leaving the output as it is on a 304")
+	rootCmd.Flags().StringVarP(&opts.TimeCond, "time-cond", "z", "", "ask only for a document modified after `time`, a date or the modification time of a file, or before it with a leading -")
+	rootCmd.Flags().BoolVarP(&opts.RemoteTime, "remote-time", "R", false, "set the modification time of the output file from the Last-Modified response header")
+	rootCmd.Flags().StringVar(&opts.OutputDir, "output-dir", "", "save -o and -O files in `dir`, unless given an absolute path")
+	rootCmd.Flags().StringVar(&opts.AbstractSocket, "abstract-unix-socket", "", "like --unix-socket, but connect to the socket with this `name` in the Linux abstract namespace")
+	rootCmd.Flags().StringVar(&opts.UnixSocket, "unix-socket", "", "connect through the Unix domain socket at `path` instead of to the URL's host and port")
+	rootCmd.MarkFlagsMutuallyExclusive("unix-socket", "abstract-unix-socket")
+	rootCmd.Flags().StringArrayVar(&opts.Resolve, "resolve", nil, "connect to ADDRESS for requests to HOST and PORT, given as `HOST:PORT:ADDRESS` (repeatable)")
+	rootCmd.Flags().BoolVar(&opts.PathAsIs, "path-as-is", false, "send the URL path exactly as given, without resolving . and .. segments")
+	rootCmd.Flags().StringVar(&opts.RequestTarget, "request-target", "", "send `target` on the request line instead of the path of the URL, such as * for OPTIONS *")
+	rootCmd.Flags().BoolVarP(&opts.RemoteName, "remote-name", "O", false, "write the response body to a local file named like the remote file")
+	rootCmd.Flags().StringVarP(&opts.Upload, "upload-file", "T", "", "upload `file` in a PUT request, or stdin for \"-\"; a URL ending in / gets the file name appended")
+	rootCmd.Flags().StringVarP(&opts.User, "user", "u", "", "`user:password` to send with HTTP Basic authentication")
+	rootCmd.Flags().StringVar(&opts.Bearer, "oauth2-bearer", "", "send an OAuth 2.0 Bearer `token` in the Authorization header")
+	rootCmd.Flags().BoolVar(&opts.Pretty, "pretty", false, "re-indent a JSON response body before writing it")
+	rootCmd.Flags().StringVarP(&opts.Referer, "referer", "e", "", "Referer `URL` to send; append \";auto\" to update it on each redirect with -L")
+	rootCmd.Flags().BoolVar(&opts.TLSv10, "tlsv1.0", false, "use TLS 1.0 or later")
+	rootCmd.Flags().BoolVar(&opts.TLSv11, "tlsv1.1", false, "use TLS 1.1 or later")
+	rootCmd.Flags().BoolVar(&opts.TLSv12, "tlsv1.2", false, "use TLS 1.2 or later")
+	rootCmd.Flags().BoolVar(&opts.TLSv13, "tlsv1.3", false, "use TLS 1.3 or later")
+	rootCmd.Flags().StringVar(&opts.SNI, "sni", "", "send `hostname` as the TLS server name, and verify the certificate against it, instead of the URL host")
+	rootCmd.Flags().StringVar(&opts.ALPN, "alpn", "", "offer these protocols through ALPN in the TLS handshake, given as a comma-separated `list` such as h2,http/1.1 (default http/1.1, or h2,http/1.1 with --http2)")
+	rootCmd.Flags().BoolVar(&opts.NoALPN, "no-alpn", false, "send no ALPN extension in the TLS handshake")
+	rootCmd.MarkFlagsMutuallyExclusive("alpn", "no-alpn")
+	rootCmd.Flags().StringVar(&opts.TLSMax, "tls-max", "", "highest TLS `version` to allow: 1.0, 1.1, 1.2 or 1.3")
+	rootCmd.Flags().StringVar(&opts.Trace, "trace", "", "write a hex and ASCII dump of all the data sent and received to `file`, or to stderr for \"-\"")
+	rootCmd.Flags().StringVar(&opts.TraceASCII, "trace-ascii", "", "like --trace, but without the hex dump")
+	rootCmd.MarkFlagsMutuallyExclusive("trace", "trace-ascii")
+	rootCmd.Flags().StringVarP(&opts.UserAgent, "user-agent", "A", def.UserAgent, "User-Agent header to send; an empty value sends none")
+	rootCmd.Flags().BoolVarP(&opts.Silent, "silent", "s", false, "don't show the progress meter or error messages")
+	rootCmd.Flags().BoolVarP(&opts.ShowError, "show-error", "S", false, "show error messages even with -s")
+	rootCmd.Flags().BoolVar(&opts.NoProgressMeter, "no-progress-meter", false, "don't show the progress meter, but still show error messages and warnings")
+	rootCmd.Flags().BoolVarP(&opts.Verbose, "verbose", "v", false, "print the request, response headers and connection info to stderr")
+	rootCmd.Flags().StringVar(&opts.StderrFile, "stderr", "", "write error messages, warnings, -v output and the progress meter to `file` instead of stderr, or to stdout for \"-\"")
+	rootCmd.Flags().StringVarP(&opts.WriteOut, "write-out", "w", "", "print `format` to stdout after the transfer, expanding variables such as %{http_code}")
 }
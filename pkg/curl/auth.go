@@ -0,0 +1,21 @@
+package curl
+
+import (
+	"encoding/base64"
+	"net/url"
+	"strings"
+)
+
+// basicAuth returns the Authorization header value for a -u "user:password"
+// string. Without a colon the whole string is the user name and the
+// password is empty.
+func basicAuth(userinfo string) string {
+	user, password, _ := strings.Cut(userinfo, ":")
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+}
+
+// sameHost reports whether a and b name the same host, so that credentials
+// meant for one may be sent to the other.
+func sameHost(a, b *url.URL) bool {
+	return strings.EqualFold(a.Hostname(), b.Hostname())
+}
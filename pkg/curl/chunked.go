@@ -0,0 +1,195 @@
+package curl
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// chunkedReader decodes a body sent with "Transfer-Encoding: chunked",
+// yielding the chunk data without its framing. Trailer fields after the last
+// chunk are collected rather than returned as body data.
+type chunkedReader struct {
+	r       *bufio.Reader
+	left    int64 // unread bytes of the current chunk
+	started bool  // whether a chunk has been read, and so needs its CRLF skipped
+	err     error
+	trailer []byte
+}
+
+func newChunkedReader(r io.Reader) *chunkedReader {
+	return &chunkedReader{r: bufio.NewReader(r)}
+}
+
+func (cr *chunkedReader) Read(p []byte) (int, error) {
+	if cr.err != nil {
+		return 0, cr.err
+	}
+	if cr.left == 0 {
+		if cr.err = cr.nextChunk(); cr.err != nil {
+			return 0, cr.err
+		}
+	}
+
+	if int64(len(p)) > cr.left {
+		p = p[:cr.left]
+	}
+	n, err := cr.r.Read(p)
+	cr.left -= int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	cr.err = err
+	return n, err
+}
+
+// nextChunk consumes the CRLF ending the previous chunk and the size line of
+// the next one. At the last chunk it reads the trailer and returns io.EOF.
+func (cr *chunkedReader) nextChunk() error {
+	if cr.started {
+		if err := cr.expectCRLF(); err != nil {
+			return err
+		}
+	}
+	cr.started = true
+
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+	sizeField, _, _ := strings.Cut(line, ";") // drop chunk extensions
+	size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("invalid chunk size line %q", line)
+	}
+	if size > 0 {
+		cr.left = size
+		return nil
+	}
+
+	for {
+		line, err := cr.readLine()
+		if err != nil {
+			return err
+		}
+		if line == "" {
+			return io.EOF
+		}
+		cr.trailer = append(cr.trailer, line+"\r\n"...)
+	}
+}
+
+// readLine reads a CRLF-terminated line, without the line ending.
+func (cr *chunkedReader) readLine() (string, error) {
+	line, err := cr.r.ReadString('\n')
+	if err == io.EOF {
+		return "", io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func (cr *chunkedReader) expectCRLF() error {
+	line, err := cr.readLine()
+	if err != nil {
+		return err
+	}
+	if line != "" {
+		return errors.New("malformed chunked encoding: missing CRLF after chunk data")
+	}
+	return nil
+}
+
+// chunkedWriter frames what is written to it as chunks of a body sent with
+// "Transfer-Encoding: chunked", one per write.
+type chunkedWriter struct {
+	w io.Writer
+}
+
+func (cw *chunkedWriter) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		// An empty chunk would end the body.
+		return 0, nil
+	}
+	if _, err := fmt.Fprintf(cw.w, "%x\r\n", len(p)); err != nil {
+		return 0, err
+	}
+	if _, err := cw.w.Write(p); err != nil {
+		return 0, err
+	}
+	if _, err := io.WriteString(cw.w, "\r\n"); err != nil {
+		return 0, err
+	}
+	if f, ok := cw.w.(interface{ Flush() error }); ok {
+		// Send each chunk as soon as it is read, as a slow producer on
+		// stdin may take a while with the next.
+		if err := f.Flush(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// close writes the last chunk, which ends the body.
+func (cw *chunkedWriter) close() error {
+	_, err := io.WriteString(cw.w, "0\r\n\r\n")
+	return err
+}
+
+// decodeChunked strips the chunked framing from body, returning the decoded
+// data and any raw trailer fields that followed the last chunk.
+func decodeChunked(body []byte) (data, trailer []byte, err error) {
+	cr := newChunkedReader(bytes.NewReader(body))
+	data, err = io.ReadAll(cr)
+	return data, cr.trailer, err
+}
+
+// isChunked reports whether the raw response header block head declares a
+// chunked body.
+func isChunked(head []byte) bool {
+	te, _ := headerValue(head, "Transfer-Encoding")
+	return hasChunked(te)
+}
+
+// hasChunked reports whether chunked is among the codings of te, a
+// Transfer-Encoding header value. It should come last, but a body is taken
+// to be framed by it wherever it is listed.
+func hasChunked(te string) bool {
+	for _, coding := range strings.Split(te, ",") {
+		if strings.EqualFold(strings.TrimSpace(coding), "chunked") {
+			return true
+		}
+	}
+	return false
+}
+
+// transferCodings returns the codings of te, a Transfer-Encoding header
+// value, other than chunked, in the order they are to be undone once the
+// chunked framing is stripped: the reverse of the order they are listed in.
+func transferCodings(te string) []string {
+	var codings []string
+	fields := strings.Split(te, ",")
+	for i := len(fields) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(fields[i]))
+		if coding != "" && coding != "chunked" && coding != "identity" {
+			codings = append(codings, coding)
+		}
+	}
+	return codings
+}
+
+// checkTransferCodings fails for a coding in codings that cannot be undone.
+func checkTransferCodings(codings []string) error {
+	for _, coding := range codings {
+		if !knownCoding(coding) {
+			return exitErrorf(exitBadEncoding, "Unsupported transfer encoding: %s", coding)
+		}
+	}
+	return nil
+}
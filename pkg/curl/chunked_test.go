@@ -0,0 +1,97 @@
+package curl
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestTransferCodings(t *testing.T) {
+	tests := []struct {
+		te   string
+		want []string
+	}{
+		{"chunked", nil},
+		{"gzip, chunked", []string{"gzip"}},
+		{"chunked, gzip", []string{"gzip"}},
+		{"deflate, gzip, chunked", []string{"gzip", "deflate"}},
+		{"identity, GZIP", []string{"gzip"}},
+	}
+	for _, tt := range tests {
+		if got := transferCodings(tt.te); !slices.Equal(got, tt.want) {
+			t.Errorf("transferCodings(%q) = %q, want %q", tt.te, got, tt.want)
+		}
+	}
+}
+
+// gzipped returns s compressed with gzip.
+func gzipped(t *testing.T, s string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := gzip.NewWriter(&b)
+	zw.Write([]byte(s))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// chunkedBody frames data as a chunked body of two chunks.
+func chunkedBody(data []byte) string {
+	half := len(data) / 2
+	return fmt.Sprintf("%x\r\n%s\r\n%x\r\n%s\r\n0\r\n\r\n", half, data[:half], len(data)-half, data[half:])
+}
+
+func TestExchangeStackedTransferCodings(t *testing.T) {
+	const text = "hello through transfer coding\n"
+	body := chunkedBody(gzipped(t, text))
+	for _, te := range []string{"gzip, chunked", "chunked, gzip"} {
+		raw := "HTTP/1.1 200 OK\r\nTransfer-Encoding: " + te + "\r\n\r\n" + body
+		resp, err := exchangeRaw(t, &Options{}, raw)
+		if err != nil {
+			t.Errorf("Transfer-Encoding %s: %v", te, err)
+			continue
+		}
+		if string(resp.Body) != text {
+			t.Errorf("Transfer-Encoding %s: body %q, want %q", te, resp.Body, text)
+		}
+	}
+}
+
+func TestExchangeGzipWithoutChunked(t *testing.T) {
+	// Without chunked, the body runs until the connection closes.
+	const text = "closed at the end\n"
+	raw := "HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip\r\n\r\n" + string(gzipped(t, text))
+	resp, err := exchangeRaw(t, &Options{}, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != text {
+		t.Errorf("body %q, want %q", resp.Body, text)
+	}
+}
+
+func TestExchangeUnknownTransferCoding(t *testing.T) {
+	raw := "HTTP/1.1 200 OK\r\nTransfer-Encoding: foo, chunked\r\n\r\n" + chunkedBody([]byte("data"))
+	_, err := exchangeRaw(t, &Options{}, raw)
+	if err == nil {
+		t.Fatal("exchange of an unknown transfer coding succeeded")
+	}
+	if code := Classify(err).Code; code != exitBadEncoding {
+		t.Errorf("exit code = %d, want %d", code, exitBadEncoding)
+	}
+}
+
+func TestExchangeRawKeepsTransferCodings(t *testing.T) {
+	body := chunkedBody(gzipped(t, "x"))
+	raw := "HTTP/1.1 200 OK\r\nTransfer-Encoding: gzip, chunked\r\n\r\n" + body
+	resp, err := exchangeRaw(t, &Options{Raw: true}, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != body {
+		t.Errorf("--raw body %q, want the framing kept: %q", resp.Body, body)
+	}
+}
@@ -0,0 +1,234 @@
+// Package curl is the HTTP client behind the build-your-own-curl command:
+// requests go over connections it dials and speaks HTTP on itself rather
+// than through net/http. The command is a thin layer over it that turns
+// flags into Options and hands them to Client.Run; other programs can do
+// the same, or make single requests with Client.Do.
+//
+//	c := &curl.Client{FollowRedirects: true, Timeout: 10 * time.Second}
+//	resp, err := c.Do(ctx, &curl.Request{URL: "https://example.com"})
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Client makes requests with the same engine as the command line. Its zero
+// value is ready to use. Connections are kept alive and reused from one
+// call to the next, so a Client should be reused rather than made for
+// each request.
+type Client struct {
+	Timeout         time.Duration // bounds each call to Do, or 0 for no limit
+	FollowRedirects bool          // whether to follow redirects, as -L does
+	MaxRedirects    int           // redirects to follow at most, or 0 for that of Options, or -1 for no limit
+	TLSConfig       *tls.Config   // TLS settings for https, or nil for those of Options
+	Header          http.Header   // headers sent with every request, ahead of those of the Request
+
+	// Options are the settings Do builds each request from, as if given
+	// on the command line, or nil for DefaultOptions with the diagnostics
+	// discarded. The URL, method and body come from the Request instead;
+	// -v output and warnings go to stderr as they would from the command.
+	Options *Options
+
+	once     sync.Once
+	mu       sync.Mutex
+	jar      *cookieJar
+	pool     *connPool
+	settings string      // connSettings of the options the pooled connections were made with
+	doTLS    *tls.Config // TLS settings Do made from those options
+}
+
+// Request is a request to make with Client.Do.
+type Request struct {
+	Method string // GET when empty, or POST when Body is set
+	URL    string
+	Header http.Header
+	Body   []byte // nil for no body
+}
+
+// init makes the state c keeps between calls.
+func (c *Client) init() {
+	c.once.Do(func() {
+		c.jar, c.pool = &cookieJar{}, newConnPool()
+	})
+}
+
+// reuse closes the idle connections unless they were made with the
+// options summed up by settings, so that a connection is only reused by a
+// request that would have made it the same way.
+func (c *Client) reuse(settings string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if settings != c.settings {
+		c.pool.closeAll()
+		c.settings, c.doTLS = settings, nil
+	}
+}
+
+// Do sends req, following redirects if c.FollowRedirects is set, and
+// returns the final response. Transfer codings such as chunked are undone
+// in its Body, and so is a Content-Encoding the options would have the
+// command decode. A response whose header block goes past the
+// --max-headers-size limit of the options, 100 KB unless they say
+// otherwise, fails with exit status 100.
+func (c *Client) Do(ctx context.Context, req *Request) (*Response, error) {
+	o, method := c.request(req)
+	if !ValidMethod(method) {
+		return nil, fmt.Errorf("invalid request method %q", method)
+	}
+	u, err := ParseURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+	t, err := c.transfer(o)
+	if err != nil {
+		return nil, err
+	}
+	if req.Body != nil {
+		t.body, t.contentType = req.Body, "application/x-www-form-urlencoded"
+	}
+
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	hops, err := t.follow(ctx, method, u)
+	if err != nil {
+		return nil, err
+	}
+	resp := hops[len(hops)-1]
+	if encoding := resp.header("Content-Encoding"); t.decodes(encoding) {
+		if resp.Body, err = decodeContent(encoding, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	return resp, nil
+}
+
+// WriteRequest writes req to w as Do would send it, for the first hop.
+func (c *Client) WriteRequest(w io.Writer, req *Request) error {
+	o, method := c.request(req)
+	u, err := ParseURL(req.URL)
+	if err != nil {
+		return err
+	}
+	t := &transfer{Options: o, jar: c.jar, pool: c.pool}
+	if req.Body != nil {
+		t.body, t.contentType = req.Body, "application/x-www-form-urlencoded"
+	}
+	r, err := newRequest(t, t.firstHop(method, u))
+	if err != nil {
+		return err
+	}
+	return r.write(w)
+}
+
+// request returns the options and the method to make req with.
+func (c *Client) request(req *Request) (*Options, string) {
+	c.init()
+	o := DefaultOptions()
+	o.Silent, o.errOut = true, io.Discard
+	if c.Options != nil {
+		o = *c.Options
+	}
+	o.Location = o.Location || c.FollowRedirects
+	if c.MaxRedirects != 0 {
+		o.MaxRedirs = c.MaxRedirects
+	}
+	o.Headers = append(slices.Clip(o.Headers), headerLines(c.Header)...)
+	o.Headers = append(o.Headers, headerLines(req.Header)...)
+
+	method := req.Method
+	if method == "" {
+		method = "GET"
+		if req.Body != nil {
+			method = "POST"
+		}
+	}
+	return &o, method
+}
+
+// transfer returns a transfer made with o that shares the cookies and
+// connections of c.
+func (c *Client) transfer(o *Options) (*transfer, error) {
+	settings := o.connSettings()
+	if c.TLSConfig != nil {
+		settings += fmt.Sprintf(" %p", c.TLSConfig)
+	}
+	c.reuse(settings)
+
+	t := &transfer{Options: o, jar: c.jar, pool: c.pool}
+	if err := t.setupConn(); err != nil {
+		return nil, err
+	}
+	if err := t.setupLimits(); err != nil {
+		return nil, err
+	}
+	// Go on with the TLS settings made for the connections in the pool, so
+	// that TLS sessions are resumed on new ones.
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.doTLS == nil {
+		c.doTLS = t.tlsConfig
+		if c.TLSConfig != nil {
+			c.doTLS = c.TLSConfig.Clone()
+			if len(c.doTLS.NextProtos) == 0 {
+				c.doTLS.NextProtos = []string{"http/1.1"}
+			}
+		}
+	}
+	t.tlsConfig = c.doTLS
+	return t, nil
+}
+
+// Run fetches urls as the command line does with the options o, through
+// the connections c keeps alive unless o.NoKeepalive turns reuse off. The
+// diagnostics go where o says, to stderr by default, and so does the
+// report of each failure; the error returned, with the exit status of the
+// last failure, carries nothing more to report.
+func (c *Client) Run(ctx context.Context, o *Options, urls []string) error {
+	c.init()
+	c.reuse(o.connSettings())
+	closeStderr, err := o.openStderr()
+	if err != nil {
+		return err
+	}
+	defer closeStderr()
+	if err := runAll(ctx, o, urls, c.pool); err != nil {
+		return &ExitError{Code: o.Report(err)}
+	}
+	return nil
+}
+
+// CloseIdleConnections closes the connections kept alive for reuse.
+func (c *Client) CloseIdleConnections() {
+	if c.pool != nil {
+		c.pool.closeAll()
+	}
+}
+
+// RawHeader returns the status line and headers of r as they were
+// received, up to and including the blank line that ends them.
+func (r *Response) RawHeader() []byte {
+	return r.rawHeader
+}
+
+// headerLines returns h as "Name: value" lines in the form -H takes, sorted
+// by name so that requests come out the same each time.
+func headerLines(h http.Header) []string {
+	var lines []string
+	for _, name := range slices.Sorted(maps.Keys(h)) {
+		for _, v := range h[name] {
+			lines = append(lines, name+": "+v)
+		}
+	}
+	return lines
+}
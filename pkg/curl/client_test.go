@@ -0,0 +1,174 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDoFollowsRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/from", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/to", http.StatusFound)
+	})
+	mux.HandleFunc("/to", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("landed"))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	c := &Client{}
+	resp, err := c.Do(context.Background(), &Request{URL: srv.URL + "/from"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusFound {
+		t.Errorf("without FollowRedirects, status = %d, want %d", resp.StatusCode, http.StatusFound)
+	}
+
+	c = &Client{FollowRedirects: true}
+	if resp, err = c.Do(context.Background(), &Request{URL: srv.URL + "/from"}); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || string(resp.Body) != "landed" {
+		t.Errorf("with FollowRedirects, got %d %q, want 200 \"landed\"", resp.StatusCode, resp.Body)
+	}
+
+	c = &Client{FollowRedirects: true, MaxRedirects: -1}
+	if _, err = c.Do(context.Background(), &Request{URL: srv.URL + "/from"}); err != nil {
+		t.Errorf("with no limit on redirects: %v", err)
+	}
+}
+
+func TestDoSendsHeadersAndBody(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %s %s", r.Method, r.Header.Get("X-Client"), r.Header.Get("X-Request"), r.Header.Get("Content-Type"), body)
+	}))
+	defer srv.Close()
+
+	c := &Client{Header: http.Header{"X-Client": {"c"}}}
+	resp, err := c.Do(context.Background(), &Request{
+		URL:    srv.URL,
+		Header: http.Header{"X-Request": {"r"}},
+		Body:   []byte("a=1"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "POST c r application/x-www-form-urlencoded a=1"; string(resp.Body) != want {
+		t.Errorf("server saw %q, want %q", resp.Body, want)
+	}
+	if got := resp.Headers["Content-Type"]; len(got) != 1 || !strings.HasPrefix(got[0], "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", got)
+	}
+	if raw := string(resp.RawHeader()); !strings.HasPrefix(raw, "HTTP/1.1 200 OK\r\n") || !strings.HasSuffix(raw, "\r\n\r\n") {
+		t.Errorf("RawHeader() = %q, want the header block as received", raw)
+	}
+
+	if resp, err = c.Do(context.Background(), &Request{Method: "PUT", URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "PUT c   "; string(resp.Body) != want {
+		t.Errorf("server saw %q, want %q", resp.Body, want)
+	}
+}
+
+func TestDoInvalidMethod(t *testing.T) {
+	c := &Client{}
+	if _, err := c.Do(context.Background(), &Request{Method: "BAD METHOD", URL: "http://127.0.0.1:1/"}); err == nil {
+		t.Error("Do with a method containing a space succeeded")
+	}
+}
+
+func TestDoTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	c := &Client{Timeout: 100 * time.Millisecond}
+	start := time.Now()
+	_, err := c.Do(context.Background(), &Request{URL: srv.URL})
+	if err == nil {
+		t.Fatal("Do succeeded, want a timeout")
+	}
+	if code := Classify(err).Code; code != exitTimeout {
+		t.Errorf("exit code = %d (%v), want %d", code, err, exitTimeout)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Do took %s, want it cut short after 100ms", elapsed)
+	}
+}
+
+func TestDoMaxHeadersSize(t *testing.T) {
+	big := "HTTP/1.1 200 OK\r\n" +
+		"X-Big: " + strings.Repeat("a", 200*1024) + "\r\n" +
+		"Content-Length: 2\r\n" +
+		"\r\n" +
+		"ok"
+	base := scriptedServer(t, map[string]string{"/": big})
+
+	c := &Client{}
+	_, err := c.Do(context.Background(), &Request{URL: base + "/"})
+	if err == nil {
+		t.Fatal("Do of a 200 KB header block succeeded, want the 100k default limit to refuse it")
+	}
+	if code := Classify(err).Code; code != exitTooLarge {
+		t.Errorf("exit code = %d (%v), want %d", code, err, exitTooLarge)
+	}
+
+	o := DefaultOptions()
+	o.MaxHeadersSize = "0"
+	c = &Client{Options: &o}
+	resp, err := c.Do(context.Background(), &Request{URL: base + "/"})
+	if err != nil {
+		t.Fatalf("with --max-headers-size 0: %v", err)
+	}
+	if string(resp.Body) != "ok" {
+		t.Errorf("body = %q, want \"ok\"", resp.Body)
+	}
+}
+
+func TestDoReusesConnections(t *testing.T) {
+	var conns atomic.Int32
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	srv.Start()
+	defer srv.Close()
+
+	c := &Client{}
+	defer c.CloseIdleConnections()
+	for range 3 {
+		if _, err := c.Do(context.Background(), &Request{URL: srv.URL}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := conns.Load(); n != 1 {
+		t.Errorf("3 requests made %d connections, want 1", n)
+	}
+
+	c.CloseIdleConnections()
+	if _, err := c.Do(context.Background(), &Request{URL: srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if n := conns.Load(); n != 2 {
+		t.Errorf("after CloseIdleConnections, %d connections, want 2", n)
+	}
+}
@@ -0,0 +1,89 @@
+package curl
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// readETag returns the entity tag saved in the --etag-compare file called
+// name, or "" when the file is missing or empty, in which case the request
+// is sent without a condition.
+func readETag(name string) string {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return ""
+	}
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	return string(bytes.TrimSpace(line))
+}
+
+// saveETag writes the ETag of resp to the --etag-save file called name, on
+// a line of its own. A response without one leaves the file empty.
+func saveETag(name string, resp *Response) error {
+	var data []byte
+	if etag := resp.header("ETag"); etag != "" {
+		data = []byte(etag + "\n")
+	}
+	if err := os.WriteFile(name, data, 0o666); err != nil {
+		return exitErrorf(exitWrite, "Failed to write the ETag to %s: %w", name, err)
+	}
+	return nil
+}
+
+// notModified reports whether resp says that what a conditional request
+// asked for has not changed, so that the output is left as it is.
+func (t *transfer) notModified(resp *Response) bool {
+	return resp.StatusCode == http.StatusNotModified && t.conditional()
+}
+
+// conditional reports whether requests are sent with a condition from
+// --etag-compare or -z.
+func (t *transfer) conditional() bool {
+	return t.ifNoneMatch != "" || t.timeCond.name != ""
+}
+
+// timeLayouts are the date formats -z accepts, besides a file name.
+var timeLayouts = []string{
+	http.TimeFormat,
+	time.RFC1123,
+	time.RFC1123Z,
+	time.RFC850,
+	time.ANSIC,
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+	"20060102",
+	"2 Jan 2006",
+	"Jan 2 2006",
+}
+
+// parseTimeCond parses a -z argument into the header that carries the
+// condition: If-Modified-Since for a date, or the modification time of a
+// file of that name, and If-Unmodified-Since when it starts with "-". It
+// reports false for a date it cannot make sense of.
+func parseTimeCond(arg string) (header, bool) {
+	name := "If-Modified-Since"
+	if rest, ok := strings.CutPrefix(arg, "-"); ok {
+		name, arg = "If-Unmodified-Since", rest
+	} else {
+		arg = strings.TrimPrefix(arg, "+")
+	}
+	var when time.Time
+	if fi, err := os.Stat(arg); err == nil {
+		when = fi.ModTime()
+	} else {
+		var err error
+		for _, layout := range timeLayouts {
+			if when, err = time.Parse(layout, arg); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return header{}, false
+		}
+	}
+	return header{name: name, value: when.UTC().Format(http.TimeFormat)}, true
+}
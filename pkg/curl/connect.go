@@ -0,0 +1,46 @@
+package curl
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"os"
+)
+
+// connectTunnel handles -X CONNECT, an advanced mode apart from the tunnels
+// -x opens by itself for https URLs. It asks the -x proxy, or the host of u
+// itself when there is none, to open a tunnel to the host and port of u,
+// and once that succeeds pipes stdin into the tunnel and what comes back
+// out of it to stdout, until the other end closes it.
+func (t *transfer) connectTunnel(ctx context.Context, u *url.URL) error {
+	// The connection is to the proxy, in the clear, whatever the scheme
+	// of the host the tunnel leads to.
+	conn, err := dial(ctx, t, &url.URL{Scheme: "http", Host: u.Host}, &hopTiming{})
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if err := t.tunnel(conn, u); err != nil {
+		return err
+	}
+	if t.Verbose {
+		t.infof("Tunnel established; piping stdin and stdout through it")
+	}
+
+	go func() {
+		io.Copy(conn, os.Stdin)
+		// Let the other end know nothing more is coming, while still
+		// reading what it has to say.
+		if tcp, ok := conn.(*net.TCPConn); ok {
+			tcp.CloseWrite()
+		}
+	}()
+	if _, err := io.Copy(os.Stdout, conn); err != nil {
+		return exitErrorf(exitRecv, "Recv failure: %w", err)
+	}
+	return ctx.Err()
+}
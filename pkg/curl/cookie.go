@@ -0,0 +1,285 @@
+package curl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cookie is a cookie as stored in a Netscape-format cookie file.
+type cookie struct {
+	domain            string
+	includeSubdomains bool
+	path              string
+	secure            bool
+	httpOnly          bool
+	expires           int64 // Unix time; 0 for a session cookie
+	name              string
+	value             string
+}
+
+// cookieJar holds the cookies known to a transfer. It is shared by the
+// transfers that -Z runs at the same time.
+type cookieJar struct {
+	mu      sync.Mutex
+	cookies []*cookie
+}
+
+// newCookieJar returns the jar for a -b argument. A value containing "=" is
+// a literal cookie string and leaves the jar empty; anything else names a
+// cookie file to load. A missing file is not an error, matching curl.
+func newCookieJar(arg string) (*cookieJar, error) {
+	jar := &cookieJar{}
+	if arg == "" || strings.Contains(arg, "=") {
+		return jar, nil
+	}
+
+	f, err := os.Open(arg)
+	if errors.Is(err, fs.ErrNotExist) {
+		return jar, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		c, err := parseCookieLine(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", arg, line, err)
+		}
+		if c != nil {
+			jar.cookies = append(jar.cookies, c)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return jar, nil
+}
+
+// parseCookieLine parses one line of a Netscape cookie file. Blank lines
+// and comments yield a nil cookie.
+func parseCookieLine(line string) (*cookie, error) {
+	httpOnly := false
+	if rest, ok := strings.CutPrefix(line, "#HttpOnly_"); ok {
+		line, httpOnly = rest, true
+	}
+	if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+
+	fields := strings.Split(line, "\t")
+	if len(fields) == 6 {
+		fields = append(fields, "") // a cookie with an empty value
+	}
+	if len(fields) != 7 {
+		return nil, fmt.Errorf("expected 7 tab-separated fields, got %d", len(fields))
+	}
+	expires, err := strconv.ParseInt(fields[4], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid expiry %q", fields[4])
+	}
+
+	return &cookie{
+		domain:            fields[0],
+		includeSubdomains: strings.EqualFold(fields[1], "TRUE"),
+		path:              fields[2],
+		secure:            strings.EqualFold(fields[3], "TRUE"),
+		httpOnly:          httpOnly,
+		expires:           expires,
+		name:              fields[5],
+		value:             fields[6],
+	}, nil
+}
+
+// matches reports whether c should be sent with a request to u.
+func (c *cookie) matches(u *url.URL, now time.Time) bool {
+	if c.expires != 0 && c.expires < now.Unix() {
+		return false
+	}
+	if c.secure && u.Scheme != "https" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	domain := strings.ToLower(strings.TrimPrefix(c.domain, "."))
+	if host != domain && !(c.includeSubdomains && domainMatch(host, domain)) {
+		return false
+	}
+
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	return path == c.path || strings.HasPrefix(path, strings.TrimSuffix(c.path, "/")+"/")
+}
+
+// header returns the Cookie header value for a request to u, joining the
+// matching cookies with "; ", or "" when none match.
+func (j *cookieJar) header(u *url.URL) string {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	var pairs []string
+	now := time.Now()
+	for _, c := range j.cookies {
+		if c.matches(u, now) {
+			pairs = append(pairs, c.name+"="+c.value)
+		}
+	}
+	return strings.Join(pairs, "; ")
+}
+
+// store adds the cookies set by resp, a response to a request for u,
+// replacing any existing cookie with the same name, domain and path. A
+// cookie that arrives already expired removes its stored counterpart.
+func (j *cookieJar) store(resp *Response, u *url.URL) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	for _, line := range resp.Headers["Set-Cookie"] {
+		c := parseSetCookie(line, u, now)
+		if c == nil {
+			continue
+		}
+
+		kept := j.cookies[:0]
+		for _, old := range j.cookies {
+			if old.name != c.name || !strings.EqualFold(old.domain, c.domain) || old.path != c.path {
+				kept = append(kept, old)
+			}
+		}
+		j.cookies = kept
+		if c.expires == 0 || c.expires > now.Unix() {
+			j.cookies = append(j.cookies, c)
+		}
+	}
+}
+
+// parseSetCookie parses a Set-Cookie header value received from u. It
+// returns nil for values without a name, and for a Domain attribute that
+// the host of u does not domain-match, which RFC 6265 says to ignore the
+// cookie for.
+func parseSetCookie(line string, u *url.URL, now time.Time) *cookie {
+	parts := strings.Split(line, ";")
+	name, value, ok := strings.Cut(parts[0], "=")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return nil
+	}
+
+	c := &cookie{
+		domain: strings.ToLower(u.Hostname()),
+		path:   defaultCookiePath(u.Path),
+		name:   name,
+		value:  strings.Trim(strings.TrimSpace(value), `"`),
+	}
+	maxAgeSet := false
+	for _, attr := range parts[1:] {
+		key, val, _ := strings.Cut(attr, "=")
+		key, val = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(val)
+		switch key {
+		case "domain":
+			if d := strings.ToLower(strings.TrimPrefix(val, ".")); d != "" {
+				if !domainMatch(strings.ToLower(u.Hostname()), d) {
+					return nil
+				}
+				c.domain, c.includeSubdomains = "."+d, true
+			}
+		case "path":
+			if strings.HasPrefix(val, "/") {
+				c.path = val
+			}
+		case "secure":
+			c.secure = true
+		case "httponly":
+			c.httpOnly = true
+		case "max-age":
+			secs, err := strconv.ParseInt(val, 10, 64)
+			if err != nil {
+				continue
+			}
+			maxAgeSet = true
+			if secs <= 0 {
+				c.expires = 1 // already expired
+			} else {
+				c.expires = now.Unix() + secs
+			}
+		case "expires":
+			if maxAgeSet {
+				continue
+			}
+			if t, err := http.ParseTime(val); err == nil {
+				c.expires = max(t.Unix(), 1)
+			}
+		}
+	}
+	return c
+}
+
+// domainMatch reports whether host domain-matches domain, both lowercase, as
+// in RFC 6265: it is domain itself, or a name under it but not an IP
+// address.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return false
+	}
+	return strings.HasSuffix(host, "."+domain)
+}
+
+// defaultCookiePath returns the path a cookie applies to when Set-Cookie
+// names none: the directory of the request path, as in RFC 6265.
+func defaultCookiePath(p string) string {
+	i := strings.LastIndex(p, "/")
+	if i <= 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// save writes the jar to the file called name in Netscape format.
+func (j *cookieJar) save(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := bufio.NewWriter(f)
+	w.WriteString("# Netscape HTTP Cookie File\n")
+	w.WriteString("# This file was generated by build-your-own-curl. Edit at your own risk.\n\n")
+	for _, c := range j.cookies {
+		domain := c.domain
+		if c.httpOnly {
+			domain = "#HttpOnly_" + domain
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(c.includeSubdomains), c.path, netscapeBool(c.secure), c.expires, c.name, c.value)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
@@ -0,0 +1,46 @@
+package curl
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseSetCookieDomain(t *testing.T) {
+	tests := []struct {
+		host, line string
+		domain     string // domain the cookie is kept for, or "" if it is ignored
+	}{
+		{"example.com", "a=1", "example.com"},
+		{"example.com", "a=1; Domain=example.com", ".example.com"},
+		{"www.example.com", "a=1; Domain=.example.com", ".example.com"},
+		{"WWW.Example.com", "a=1; Domain=EXAMPLE.com", ".example.com"},
+		{"evil.example", "a=1; Domain=.bank.example", ""},
+		{"example.com", "a=1; Domain=www.example.com", ""},
+		{"notexample.com", "a=1; Domain=example.com", ""},
+		{"10.0.0.1", "a=1; Domain=0.0.1", ""},
+		{"10.0.0.1", "a=1; Domain=10.0.0.1", ".10.0.0.1"},
+	}
+	for _, tt := range tests {
+		u := &url.URL{Scheme: "http", Host: tt.host, Path: "/"}
+		c := parseSetCookie(tt.line, u, time.Now())
+		got := ""
+		if c != nil {
+			got = c.domain
+		}
+		if got != tt.domain {
+			t.Errorf("Set-Cookie %q from %s: domain %q, want %q", tt.line, tt.host, got, tt.domain)
+		}
+	}
+}
+
+func TestCookieJarRejectsForeignDomain(t *testing.T) {
+	j := &cookieJar{}
+	from := &url.URL{Scheme: "http", Host: "evil.example", Path: "/"}
+	j.store(&Response{Headers: map[string][]string{"Set-Cookie": {"session=stolen; Domain=.bank.example"}}}, from)
+
+	to := &url.URL{Scheme: "http", Host: "www.bank.example", Path: "/"}
+	if got := j.header(to); got != "" {
+		t.Errorf("Cookie sent to %s = %q, want none", to.Host, got)
+	}
+}
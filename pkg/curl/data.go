@@ -0,0 +1,105 @@
+package curl
+
+import (
+	"io"
+	"os"
+	"strings"
+)
+
+// DataKind tells the flags that give request data apart.
+type DataKind int
+
+const (
+	DataPlain     DataKind = iota // -d: "@file" reads a file, dropping newlines
+	DataRaw                       // --data-raw: sent as given
+	DataURLEncode                 // --data-urlencode
+	DataJSON                      // --json: "@file" reads a file as it is
+)
+
+// DataArg is one piece of request data given on the command line.
+type DataArg struct {
+	Value string
+	Kind  DataKind
+}
+
+// RequestData returns the request data built from -d, --data-raw,
+// --data-urlencode and --json, with multiple values joined by "&" as curl
+// does. Consecutive --json values are concatenated instead.
+func (o *Options) RequestData() (string, error) {
+	var b strings.Builder
+	for i, d := range o.Data {
+		var part string
+		var err error
+		switch {
+		case d.Kind == DataURLEncode:
+			part, err = urlencodeData(d.Value)
+		case d.Kind == DataPlain && strings.HasPrefix(d.Value, "@"):
+			var data []byte
+			data, err = ReadDataFile(d.Value[1:])
+			part = strings.NewReplacer("\r", "", "\n", "").Replace(string(data))
+		case d.Kind == DataJSON && strings.HasPrefix(d.Value, "@"):
+			var data []byte
+			data, err = ReadDataFile(d.Value[1:])
+			part = string(data)
+		default:
+			part = d.Value
+		}
+		if err != nil {
+			return "", err
+		}
+		if i > 0 && !(d.Kind == DataJSON && o.Data[i-1].Kind == DataJSON) {
+			b.WriteByte('&')
+		}
+		b.WriteString(part)
+	}
+	return b.String(), nil
+}
+
+// isJSON reports whether the request data includes --json, which makes it
+// a JSON body.
+func (o *Options) isJSON() bool {
+	for _, d := range o.Data {
+		if d.Kind == DataJSON {
+			return true
+		}
+	}
+	return false
+}
+
+// urlencodeData encodes a --data-urlencode value, given as "content",
+// "=content", "name=content", "@file" or "name@file". Only the content is
+// encoded; a name is kept as it is.
+func urlencodeData(s string) (string, error) {
+	name, content := "", s
+	if i := strings.IndexAny(s, "=@"); i >= 0 {
+		name, content = s[:i], s[i+1:]
+		if s[i] == '@' {
+			b, err := ReadDataFile(content)
+			if err != nil {
+				return "", err
+			}
+			content = string(b)
+		}
+	}
+
+	encoded := queryEscape(content)
+	if name == "" {
+		return encoded, nil
+	}
+	return name + "=" + encoded, nil
+}
+
+// ReadDataFile reads the file called name, or stdin for "-".
+func ReadDataFile(name string) ([]byte, error) {
+	var b []byte
+	var err error
+	if name == "-" {
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, exitErrorf(exitRead, "Failed to read data from %s: %w", name, err)
+	}
+	return b, nil
+}
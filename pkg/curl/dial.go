@@ -0,0 +1,392 @@
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// defaultPort returns the port used for scheme when the URL does not name one.
+func defaultPort(scheme string) string {
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// dial connects to the host named by u for transfer t, or to its -x proxy,
+// or to wherever --connect-to and then --resolve send it, or to the
+// --unix-socket. Plain http URLs get a bare TCP connection; https URLs are
+// wrapped in TLS, using the hostname for SNI and certificate verification.
+// --connect-timeout bounds both the TCP connect and the TLS handshake, but
+// not anything sent or read afterwards.
+func dial(parent context.Context, t *transfer, u *url.URL, timing *hopTiming) (net.Conn, error) {
+	target := u
+	if t.proxy != nil {
+		target = t.proxy
+	}
+	port := target.Port()
+	if port == "" {
+		port = defaultPort(target.Scheme)
+	}
+	host, port := t.connectTarget(target.Hostname(), port)
+	if resolved, ok := t.resolveAddr(host, port); ok {
+		host = resolved
+	}
+
+	ctx := parent
+	if t.ConnectTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, seconds(t.ConnectTimeout))
+		defer cancel()
+	}
+
+	start := time.Now()
+	conn, err := t.connect(ctx, u, host, port, timing)
+	if err != nil && ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+		return nil, exitErrorf(exitTimeout, "Connection timed out after %d ms", time.Since(start).Milliseconds())
+	}
+	return conn, err
+}
+
+// connect resolves host, dials port on it, or dials the --unix-socket or
+// --abstract-unix-socket, and for https URLs performs the TLS handshake,
+// sending the --sni name, if any, in place of the URL host. The
+// time each stage completes is recorded in timing.
+func (t *transfer) connect(ctx context.Context, u *url.URL, host, port string, timing *hopTiming) (net.Conn, error) {
+	var conn net.Conn
+	var err error
+	var d net.Dialer
+	switch {
+	case t.AbstractSocket != "":
+		var addr string
+		if addr, err = abstractSocketAddr(t.AbstractSocket); err == nil {
+			conn, err = d.DialContext(ctx, "unix", addr)
+		}
+	case t.UnixSocket != "":
+		conn, err = d.DialContext(ctx, "unix", t.UnixSocket)
+	default:
+		var addrs []netip.Addr
+		if addrs, err = t.lookup(ctx, host); err != nil {
+			return nil, err
+		}
+		timing.mark(&timing.namelookup)
+		if _, err := netip.ParseAddr(host); err != nil && t.Verbose {
+			t.infof("Host %s was resolved to %s", host, joinAddrs(addrs))
+		}
+		conn, err = t.dialAddrs(ctx, addrs, port)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.setKeepAlive(conn)
+	timing.mark(&timing.connect)
+	if u.Scheme != "https" {
+		return conn, nil
+	}
+	if t.proxy != nil {
+		if err := t.tunnel(conn, u); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	config := t.tlsConfig.Clone()
+	config.ServerName = u.Hostname()
+	if t.SNI != "" {
+		// The certificate is verified against the name sent, too.
+		config.ServerName = t.SNI
+		if t.Verbose {
+			t.infof("Sending %s as the TLS server name instead of %s", t.SNI, u.Hostname())
+		}
+	}
+	if t.Verbose && len(config.NextProtos) > 0 {
+		t.infof("ALPN: offering %s", strings.Join(config.NextProtos, ","))
+	}
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, handshakeError(err)
+	}
+	timing.mark(&timing.appconnect)
+	return tlsConn, nil
+}
+
+// defaultKeepaliveTime is how long, in seconds, a connection may sit idle
+// before TCP keepalive probes are sent, unless --keepalive-time says
+// otherwise. It is the same as curl's.
+const defaultKeepaliveTime = 60
+
+// setKeepAlive turns on TCP keepalive for conn with the --keepalive-time
+// period, or off for --no-keepalive. Unix socket connections are left as
+// they are.
+func (t *transfer) setKeepAlive(conn net.Conn) {
+	tcp, ok := conn.(*net.TCPConn)
+	if !ok {
+		return
+	}
+	if t.NoKeepalive {
+		tcp.SetKeepAlive(false)
+		return
+	}
+	period := t.KeepaliveTime
+	if period <= 0 {
+		period = defaultKeepaliveTime
+	}
+	tcp.SetKeepAlive(true)
+	tcp.SetKeepAlivePeriod(time.Duration(period) * time.Second)
+}
+
+// lookup resolves host to the addresses of the family allowed by -4 or -6,
+// with DNS-over-HTTPS for --doh-url or the --dns-servers otherwise, within
+// --resolve-timeout. An IP address is returned as it is.
+func (t *transfer) lookup(ctx context.Context, host string) (addrs []netip.Addr, err error) {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return []netip.Addr{ip}, nil
+	}
+	if t.ResolveTimeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, seconds(t.ResolveTimeout))
+		defer cancel()
+		start := time.Now()
+		defer func() {
+			if ctx.Err() == context.DeadlineExceeded && parent.Err() == nil {
+				err = exitErrorf(exitResolveHost, "Resolving timed out after %d ms: could not resolve host: %s", time.Since(start).Milliseconds(), host)
+			}
+		}()
+	}
+
+	network := strings.Replace(t.network(), "tcp", "ip", 1)
+	if t.doh != nil {
+		return t.doh.lookup(ctx, t, network, host)
+	}
+	resolver := t.resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	addrs, err = resolver.LookupNetIP(ctx, network, host)
+	var dnsErr *net.DNSError
+	if err != nil && !errors.As(err, &dnsErr) {
+		// No address of the family asked for.
+		return nil, exitErrorf(exitResolveHost, "Could not resolve host: %s", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	for i, a := range addrs {
+		addrs[i] = a.Unmap()
+	}
+	return addrs, nil
+}
+
+// joinAddrs lists addrs for -v.
+func joinAddrs(addrs []netip.Addr) string {
+	s := make([]string, len(addrs))
+	for i, a := range addrs {
+		s[i] = a.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// defaultHappyEyeballsTimeout is how long the first address family gets a
+// head start unless --happy-eyeballs-timeout-ms says otherwise.
+const defaultHappyEyeballsTimeout = 200
+
+// dialAddrs dials port on addrs, racing the two address families against
+// each other when both are present, the way RFC 8305 (Happy Eyeballs)
+// describes: the family of the first address is tried first, and the other
+// one starts after the --happy-eyeballs-timeout-ms delay, or as soon as the
+// first fails. The first connection made wins and the other attempt is
+// cancelled.
+func (t *transfer) dialAddrs(ctx context.Context, addrs []netip.Addr, port string) (net.Conn, error) {
+	var primary, fallback []netip.Addr
+	for _, a := range addrs {
+		if a.Is4() == addrs[0].Is4() {
+			primary = append(primary, a)
+		} else {
+			fallback = append(fallback, a)
+		}
+	}
+	if len(fallback) == 0 {
+		return t.dialSerial(ctx, primary, port)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result, 2)
+	race := func(addrs []netip.Addr) {
+		go func() {
+			conn, err := t.dialSerial(ctx, addrs, port)
+			results <- result{conn, err}
+		}()
+	}
+
+	race(primary)
+	pending, fallbackStarted := 1, false
+	timer := time.NewTimer(time.Duration(t.HappyEyeballsTimeout) * time.Millisecond)
+	defer timer.Stop()
+	var firstErr error
+	for pending > 0 {
+		select {
+		case <-timer.C:
+			if !fallbackStarted {
+				race(fallback)
+				pending, fallbackStarted = pending+1, true
+			}
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				// Close whatever the losing attempt still comes up with.
+				go func(n int) {
+					for ; n > 0; n-- {
+						if res := <-results; res.conn != nil {
+							res.conn.Close()
+						}
+					}
+				}(pending)
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if !fallbackStarted {
+				race(fallback)
+				pending, fallbackStarted = pending+1, true
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// dialSerial dials port on each of addrs in turn, returning the first
+// connection made or the first error if none can be.
+func (t *transfer) dialSerial(ctx context.Context, addrs []netip.Addr, port string) (net.Conn, error) {
+	var firstErr error
+	for _, a := range addrs {
+		conn, err := t.dialAddr(ctx, a, port)
+		if err == nil {
+			return conn, nil
+		}
+		var exitErr *ExitError
+		if errors.As(err, &exitErr) && exitErr.Code == exitInterface {
+			return nil, err
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, firstErr
+}
+
+// dialAddr dials port on remote, from the --interface address of the same
+// family if one was given and from the first free port of the --local-port
+// range if there is one.
+func (t *transfer) dialAddr(ctx context.Context, remote netip.Addr, port string) (net.Conn, error) {
+	var local netip.Addr
+	if t.localIPs != nil {
+		for _, ip := range t.localIPs {
+			if ip.Is4() == remote.Is4() {
+				local = ip
+				break
+			}
+		}
+		if !local.IsValid() {
+			return nil, exitErrorf(exitInterface, "Couldn't bind to interface '%s': no address to connect to %s from", t.Interface, remote)
+		}
+	}
+
+	low, high := t.localPorts.low, t.localPorts.high
+	for p := low; ; p++ {
+		var d net.Dialer
+		if local.IsValid() || p != 0 {
+			d.LocalAddr = &net.TCPAddr{IP: local.AsSlice(), Port: p}
+		}
+		conn, err := d.DialContext(ctx, t.network(), net.JoinHostPort(remote.String(), port))
+		if p == 0 || !(errors.Is(err, syscall.EADDRINUSE) || errors.Is(err, syscall.EADDRNOTAVAIL)) {
+			return conn, err
+		}
+		if p == high {
+			return nil, exitErrorf(exitInterface, "bind failed: no free local port in %d-%d", low, high)
+		}
+	}
+}
+
+// portRange is a --local-port range, or the zero value for any port.
+type portRange struct {
+	low, high int
+}
+
+// parseLocalPort parses a --local-port argument: a port or a "low-high"
+// range of them.
+func parseLocalPort(s string) (portRange, error) {
+	lowField, highField, isRange := strings.Cut(s, "-")
+	low, err := strconv.Atoi(lowField)
+	high := low
+	if err == nil && isRange {
+		high, err = strconv.Atoi(highField)
+	}
+	if err != nil || low < 1 || high > 65535 || high < low {
+		return portRange{}, fmt.Errorf("invalid --local-port %q: expected a port or a low-high range between 1 and 65535", s)
+	}
+	return portRange{low: low, high: high}, nil
+}
+
+// interfaceAddrs returns the addresses to bind to for --interface iface,
+// either an IP address or the name of a network interface, keeping only
+// those network can use. IPv6 link-local addresses are left out, as they
+// need a zone to be dialed from.
+func interfaceAddrs(iface, network string) ([]netip.Addr, error) {
+	var addrs []netip.Addr
+	if ip, err := netip.ParseAddr(iface); err == nil {
+		addrs = append(addrs, ip.Unmap())
+	} else {
+		ifi, err := net.InterfaceByName(iface)
+		if err != nil {
+			return nil, exitErrorf(exitInterface, "Couldn't bind to interface '%s': %w", iface, err)
+		}
+		ifAddrs, err := ifi.Addrs()
+		if err != nil {
+			return nil, exitErrorf(exitInterface, "Couldn't bind to interface '%s': %w", iface, err)
+		}
+		for _, a := range ifAddrs {
+			if ipNet, ok := a.(*net.IPNet); ok {
+				if ip, ok := netip.AddrFromSlice(ipNet.IP); ok && !ip.Unmap().IsLinkLocalUnicast() {
+					addrs = append(addrs, ip.Unmap())
+				}
+			}
+		}
+	}
+
+	var usable []netip.Addr
+	for _, ip := range addrs {
+		if network == "tcp" || (network == "tcp4") == ip.Is4() {
+			usable = append(usable, ip)
+		}
+	}
+	if len(usable) == 0 {
+		return nil, exitErrorf(exitInterface, "Couldn't bind to interface '%s': it has no usable address", iface)
+	}
+	return usable, nil
+}
+
+// seconds converts a duration given on the command line in fractional
+// seconds.
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
@@ -0,0 +1,159 @@
+package curl
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+)
+
+// digestChallenge holds the parameters of a WWW-Authenticate: Digest
+// challenge.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       []string
+}
+
+// answersDigest reports whether a 401 response to h is answered with
+// Digest credentials from -u, so that the request is made again. That is
+// done once, and never for a host a redirect led to.
+func (t *transfer) answersDigest(h *hop) bool {
+	return t.Digest && t.User != "" && h.authorization == "" && !h.crossHost
+}
+
+// parseDigestChallenge finds the Digest challenge among the
+// WWW-Authenticate header values of a response.
+func parseDigestChallenge(values []string) (*digestChallenge, error) {
+	for _, v := range values {
+		scheme, rest, _ := strings.Cut(strings.TrimSpace(v), " ")
+		if !strings.EqualFold(scheme, "Digest") {
+			continue
+		}
+		params := parseAuthParams(rest)
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+		}
+		for _, q := range strings.Split(params["qop"], ",") {
+			if q = strings.TrimSpace(q); q != "" {
+				c.qop = append(c.qop, q)
+			}
+		}
+		if c.nonce == "" {
+			return nil, errors.New("Digest challenge without a nonce")
+		}
+		return c, nil
+	}
+	return nil, errors.New("server did not offer Digest authentication")
+}
+
+// parseAuthParams parses the comma-separated name=value pairs of an
+// authentication challenge. Values may be quoted strings containing commas.
+func parseAuthParams(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		s = strings.TrimLeft(s, " ,")
+		name, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		rest = strings.TrimLeft(rest, " ")
+
+		var value strings.Builder
+		if strings.HasPrefix(rest, `"`) {
+			i := 1
+			for ; i < len(rest) && rest[i] != '"'; i++ {
+				if rest[i] == '\\' && i+1 < len(rest) {
+					i++
+				}
+				value.WriteByte(rest[i])
+			}
+			s = rest[min(i+1, len(rest)):]
+		} else {
+			end := strings.IndexByte(rest, ',')
+			if end < 0 {
+				end = len(rest)
+			}
+			value.WriteString(strings.TrimSpace(rest[:end]))
+			s = rest[end:]
+		}
+		params[name] = value.String()
+	}
+	return params
+}
+
+// authorize returns the Authorization header value answering the challenge
+// for a request with method and target, made as userinfo "user:password".
+func (c *digestChallenge) authorize(userinfo, method, target string) (string, error) {
+	user, password, _ := strings.Cut(userinfo, ":")
+
+	algorithm := strings.ToUpper(c.algorithm)
+	var newHash func() hash.Hash
+	switch strings.TrimSuffix(algorithm, "-SESS") {
+	case "", "MD5":
+		newHash = md5.New
+	case "SHA-256":
+		newHash = sha256.New
+	default:
+		return "", fmt.Errorf("unsupported Digest algorithm %q", c.algorithm)
+	}
+	h := func(parts ...string) string {
+		d := newHash()
+		d.Write([]byte(strings.Join(parts, ":")))
+		return hex.EncodeToString(d.Sum(nil))
+	}
+
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	cnonce := hex.EncodeToString(b)
+	const nc = "00000001"
+
+	ha1 := h(user, c.realm, password)
+	if strings.HasSuffix(algorithm, "-SESS") {
+		ha1 = h(ha1, c.nonce, cnonce)
+	}
+	ha2 := h(method, target)
+
+	qop := ""
+	for _, q := range c.qop {
+		if q == "auth" {
+			qop = q
+		}
+	}
+	var response string
+	if qop != "" {
+		response = h(ha1, c.nonce, nc, cnonce, qop, ha2)
+	} else {
+		response = h(ha1, c.nonce, ha2)
+	}
+
+	fields := []string{
+		fmt.Sprintf("username=%q", user),
+		fmt.Sprintf("realm=%q", c.realm),
+		fmt.Sprintf("nonce=%q", c.nonce),
+		fmt.Sprintf("uri=%q", target),
+	}
+	if c.algorithm != "" {
+		fields = append(fields, "algorithm="+c.algorithm)
+	}
+	fields = append(fields, fmt.Sprintf("response=%q", response))
+	if c.opaque != "" {
+		fields = append(fields, fmt.Sprintf("opaque=%q", c.opaque))
+	}
+	if qop != "" {
+		fields = append(fields, "qop="+qop, "nc="+nc, fmt.Sprintf("cnonce=%q", cnonce))
+	}
+	return "Digest " + strings.Join(fields, ", "), nil
+}
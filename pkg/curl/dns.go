@@ -0,0 +1,41 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync/atomic"
+)
+
+// newResolver returns the resolver for a --dns-servers list, a
+// comma-separated list of name servers given as host or host:port, which
+// asks them in turn. It is the system resolver when the list is empty.
+func newResolver(list string) (*net.Resolver, error) {
+	if list == "" {
+		return net.DefaultResolver, nil
+	}
+	var servers []string
+	for _, s := range strings.Split(list, ",") {
+		s = strings.TrimSpace(s)
+		if _, _, err := net.SplitHostPort(s); err != nil {
+			s = net.JoinHostPort(strings.Trim(s, "[]"), "53")
+		}
+		host, _, _ := net.SplitHostPort(s)
+		if net.ParseIP(host) == nil {
+			return nil, fmt.Errorf("invalid --dns-servers entry %q: expected an IP address with an optional port", s)
+		}
+		servers = append(servers, s)
+	}
+
+	var next atomic.Uint32
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			// Each retry of the resolver goes to the next server.
+			server := servers[int(next.Add(1)-1)%len(servers)]
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}, nil
+}
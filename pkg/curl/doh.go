@@ -0,0 +1,161 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dohResolver resolves host names with DNS-over-HTTPS (RFC 8484) for
+// --doh-url, caching the answers for their TTL. It is shared by all the
+// transfers of one invocation.
+type dohResolver struct {
+	url *url.URL
+
+	mu    sync.Mutex
+	cache map[dohKey]dohAnswer
+}
+
+// dohKey identifies a cached DoH answer.
+type dohKey struct {
+	host  string
+	qtype dnsmessage.Type
+}
+
+// dohAnswer is the addresses a DoH query returned and when they expire.
+type dohAnswer struct {
+	addrs   []netip.Addr
+	expires time.Time
+}
+
+// newDoHResolver returns a resolver that queries the --doh-url rawURL.
+func newDoHResolver(rawURL string) (*dohResolver, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, fmt.Errorf("invalid --doh-url %q: expected an https URL", rawURL)
+	}
+	return &dohResolver{url: u, cache: make(map[dohKey]dohAnswer)}, nil
+}
+
+// lookup resolves host to the addresses of the families network allows:
+// "ip4", "ip6" or "ip" for both, IPv6 first. The DoH server is reached the
+// way t reaches any host, except that its own name is resolved by the
+// system resolver.
+func (d *dohResolver) lookup(ctx context.Context, t *transfer, network, host string) ([]netip.Addr, error) {
+	var qtypes []dnsmessage.Type
+	if network != "ip4" {
+		qtypes = append(qtypes, dnsmessage.TypeAAAA)
+	}
+	if network != "ip6" {
+		qtypes = append(qtypes, dnsmessage.TypeA)
+	}
+
+	var addrs []netip.Addr
+	for _, qtype := range qtypes {
+		answer, err := d.query(ctx, t, host, qtype)
+		if err != nil {
+			return nil, exitErrorf(exitResolveHost, "Could not resolve host: %s (DoH server %s: %v)", host, d.url.Host, err)
+		}
+		addrs = append(addrs, answer...)
+	}
+	if len(addrs) == 0 {
+		return nil, exitErrorf(exitResolveHost, "Could not resolve host: %s", host)
+	}
+	return addrs, nil
+}
+
+// query returns the addresses of type qtype that the DoH server has for
+// host, from the cache while they are fresh.
+func (d *dohResolver) query(ctx context.Context, t *transfer, host string, qtype dnsmessage.Type) ([]netip.Addr, error) {
+	key := dohKey{host: strings.ToLower(host), qtype: qtype}
+	d.mu.Lock()
+	cached, ok := d.cache[key]
+	d.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.addrs, nil
+	}
+
+	msg, err := dohQuery(host, qtype)
+	if err != nil {
+		return nil, err
+	}
+	// The query goes out like a plain POST of its own, sharing only how to
+	// connect with the transfer that needs the answer.
+	o := &Options{UserAgent: defaultUserAgent, ConnectTimeout: t.ConnectTimeout, IPv4: t.IPv4, IPv6: t.IPv6}
+	dt := &transfer{Options: o, jar: &cookieJar{}, body: msg, contentType: "application/dns-message", tlsConfig: t.tlsConfig}
+	req, err := newRequest(dt, &hop{method: "POST", url: d.url, body: msg})
+	if err != nil {
+		return nil, err
+	}
+	req.setHeader("Accept", "application/dns-message")
+	resp, err := dt.roundTrip(ctx, req, d.url)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	addrs, ttl, err := dohAddrs(resp.Body, qtype)
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	d.cache[key] = dohAnswer{addrs: addrs, expires: time.Now().Add(ttl)}
+	d.mu.Unlock()
+	return addrs, nil
+}
+
+// dohQuery builds the DNS query for the records of type qtype of host. As
+// RFC 8484 recommends, its ID is 0 so that it can be cached.
+func dohQuery(host string, qtype dnsmessage.Type) ([]byte, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid host name %q", host)
+	}
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: name, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	return msg.Pack()
+}
+
+// dohAddrs returns the addresses of type qtype in the DNS response msg and
+// the shortest TTL among them.
+func dohAddrs(msg []byte, qtype dnsmessage.Type) ([]netip.Addr, time.Duration, error) {
+	var m dnsmessage.Message
+	if err := m.Unpack(msg); err != nil {
+		return nil, 0, fmt.Errorf("malformed DNS response: %w", err)
+	}
+	if m.RCode != dnsmessage.RCodeSuccess && m.RCode != dnsmessage.RCodeNameError {
+		return nil, 0, fmt.Errorf("DNS error %v", m.RCode)
+	}
+
+	var addrs []netip.Addr
+	var ttl uint32
+	for _, rr := range m.Answers {
+		var addr netip.Addr
+		switch body := rr.Body.(type) {
+		case *dnsmessage.AResource:
+			addr = netip.AddrFrom4(body.A)
+		case *dnsmessage.AAAAResource:
+			addr = netip.AddrFrom16(body.AAAA).Unmap()
+		default:
+			continue // CNAMEs are followed by the server
+		}
+		if rr.Header.Type != qtype {
+			continue
+		}
+		if len(addrs) == 0 || rr.Header.TTL < ttl {
+			ttl = rr.Header.TTL
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, time.Duration(ttl) * time.Second, nil
+}
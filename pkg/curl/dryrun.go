@@ -0,0 +1,30 @@
+package curl
+
+import (
+	"net/url"
+	"os"
+)
+
+// dryRun writes the first request of a transfer of u with method to stdout,
+// exactly as it would be sent, for --dry-run. No connection is made, so
+// nothing that depends on a response, such as a redirect or a Digest
+// challenge, comes into it.
+func (t *transfer) dryRun(method string, u *url.URL) error {
+	h := t.firstHop(method, u)
+	req, err := newRequest(t, h)
+	if err != nil {
+		return err
+	}
+	if h.upload {
+		f, err := t.openUpload()
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		req.upload = f
+	}
+	if err := req.write(os.Stdout); err != nil {
+		return writeError(err)
+	}
+	return nil
+}
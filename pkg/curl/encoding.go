@@ -0,0 +1,108 @@
+package curl
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// acceptEncoding lists the content codings --compressed asks for.
+const acceptEncoding = "gzip, deflate, br"
+
+// decodeContent undoes the Content-Encoding named by encoding on body.
+// Bodies with no encoding, or one we did not ask for, are returned as-is.
+func decodeContent(encoding string, body []byte) ([]byte, error) {
+	r, err := contentReader(encoding, bytes.NewReader(body))
+	if r == nil && err == nil {
+		return body, nil
+	}
+	if err != nil {
+		return nil, exitErrorf(exitBadEncoding, "Error while processing content unencoding: %s: %w", encoding, err)
+	}
+
+	decoded, err := io.ReadAll(r)
+	if err != nil {
+		return nil, exitErrorf(exitBadEncoding, "Error while processing content unencoding: %s: %w", encoding, err)
+	}
+	return decoded, nil
+}
+
+// contentReader returns a reader that undoes the Content-Encoding named by
+// encoding on what is read from r, or nil for no encoding or one we did not
+// ask for.
+func contentReader(encoding string, r io.Reader) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip":
+		return gzip.NewReader(r)
+	case "deflate":
+		return newDeflateReader(r)
+	case "br":
+		return &safeReader{r: brotli.NewReader(r)}, nil
+	}
+	return nil, nil
+}
+
+// knownCoding reports whether contentReader can undo the coding named by
+// encoding.
+func knownCoding(encoding string) bool {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "gzip", "x-gzip", "deflate", "br":
+		return true
+	}
+	return false
+}
+
+// decodeCodings undoes each of codings on body in turn, for a body sent
+// with a Transfer-Encoding other than just chunked.
+func decodeCodings(codings []string, body []byte) ([]byte, error) {
+	for _, coding := range codings {
+		r, err := contentReader(coding, bytes.NewReader(body))
+		if err == nil {
+			body, err = io.ReadAll(r)
+		}
+		if err != nil {
+			return nil, exitErrorf(exitBadEncoding, "Error while processing transfer unencoding: %s: %w", coding, err)
+		}
+	}
+	return body, nil
+}
+
+// isBrotli reports whether encoding names the brotli coding. A brotli body
+// is decoded even without --compressed, since unlike gzip it is of no use
+// to anyone as it is.
+func isBrotli(encoding string) bool {
+	return strings.EqualFold(strings.TrimSpace(encoding), "br")
+}
+
+// safeReader turns a panic in the decoder it wraps, on input corrupt enough
+// to trip it up, into an error.
+type safeReader struct {
+	r io.Reader
+}
+
+func (s *safeReader) Read(b []byte) (n int, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			n, err = 0, fmt.Errorf("corrupt stream: %v", p)
+		}
+	}()
+	return s.r.Read(b)
+}
+
+// newDeflateReader returns a reader for a "deflate" body. The coding is
+// meant to be zlib-wrapped, but some servers send a raw DEFLATE stream, so
+// the zlib header is only expected when one is present.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if b, _ := br.Peek(2); len(b) == 2 && b[0]&0x0f == 8 && (uint16(b[0])<<8|uint16(b[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
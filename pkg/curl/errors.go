@@ -0,0 +1,127 @@
+package curl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+)
+
+// Exit statuses, numbered like curl's error codes so scripts can tell
+// failures apart.
+const (
+	exitGeneric          = 1
+	exitUnsupported      = 1
+	exitURLMalformat     = 3
+	exitResolveHost      = 6
+	exitConnect          = 7
+	exitWeirdReply       = 8
+	exitFail             = 22
+	exitWrite            = 23
+	exitRead             = 26
+	exitTimeout          = 28
+	exitRange            = 33
+	exitSSLConnect       = 35
+	exitInterface        = 45
+	exitTooManyRedirects = 47
+	exitSend             = 55
+	exitRecv             = 56
+	exitClientCert       = 58
+	exitCertificate      = 60
+	exitBadEncoding      = 61
+	exitFileSize         = 63
+	exitSendRewind       = 65
+	exitCACert           = 77
+	exitTooLarge         = 100
+	exitInterrupted      = 130 // as a shell reports a command killed by SIGINT
+)
+
+// ExitError is an error that should end the process with a specific exit
+// status, the way curl reports failures with numbered codes.
+type ExitError struct {
+	Code int
+	Err  error // nil if the failure has already been reported
+}
+
+func (e *ExitError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("exit status %d", e.Code)
+	}
+	return e.Err.Error()
+}
+
+func (e *ExitError) Unwrap() error { return e.Err }
+
+// exitErrorf formats an error that exits with code.
+func exitErrorf(code int, format string, args ...any) error {
+	return &ExitError{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+// ErrInterrupted ends a transfer cancelled by a signal. There is nothing to
+// report beyond the exit status.
+var ErrInterrupted = &ExitError{Code: exitInterrupted}
+
+// failError returns the error -f reports for a response with status code.
+func failError(code int) error {
+	return exitErrorf(exitFail, "The requested URL returned error: %d", code)
+}
+
+// handshakeError classifies a failed TLS handshake, telling certificate
+// problems apart from other handshake failures.
+func handshakeError(err error) error {
+	var verifyErr *tls.CertificateVerificationError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &verifyErr) || errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr) || errors.As(err, &invalidErr) {
+		return &ExitError{Code: exitCertificate, Err: fmt.Errorf("SSL certificate problem: %w", err)}
+	}
+	return &ExitError{Code: exitSSLConnect, Err: fmt.Errorf("SSL connect error: %w", err)}
+}
+
+// Classify turns err into an ExitError with the curl exit status that best
+// describes it. Errors already carrying a status keep it; errors from the
+// network are recognized by type; anything else exits with a generic status.
+func Classify(err error) *ExitError {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return &ExitError{Code: exitResolveHost, Err: fmt.Errorf("Could not resolve host: %s", dnsErr.Name)}
+	}
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return &ExitError{Code: exitTimeout, Err: fmt.Errorf("Operation timed out: %w", err)}
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		cause := opErr.Err
+		var errno syscall.Errno
+		if errors.As(cause, &errno) {
+			cause = errno
+		}
+		switch opErr.Op {
+		case "dial":
+			target := "host"
+			if opErr.Addr != nil {
+				target = opErr.Addr.String()
+				if host, port, err := net.SplitHostPort(target); err == nil {
+					target = host + " port " + port
+				}
+			}
+			return &ExitError{Code: exitConnect, Err: fmt.Errorf("Failed to connect to %s: %v", target, cause)}
+		case "read":
+			return &ExitError{Code: exitRecv, Err: fmt.Errorf("Recv failure: %v", cause)}
+		case "write":
+			return &ExitError{Code: exitSend, Err: fmt.Errorf("Send failure: %v", cause)}
+		}
+	}
+	return &ExitError{Code: exitGeneric, Err: err}
+}
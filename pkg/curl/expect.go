@@ -0,0 +1,107 @@
+package curl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// expectThreshold is the body size above which a request asks the
+	// server with Expect: 100-continue whether it wants the body at all.
+	expectThreshold = 1 << 20
+
+	// defaultExpect100Timeout is how many seconds to wait for the server
+	// to answer Expect: 100-continue unless --expect100-timeout says
+	// otherwise.
+	defaultExpect100Timeout = 1
+)
+
+// expectsContinue reports whether req waits for a 100 Continue before
+// sending its body.
+func (r *request) expectsContinue() bool {
+	for _, h := range r.headers {
+		if strings.EqualFold(h.name, "Expect") && strings.EqualFold(h.value, "100-continue") {
+			return true
+		}
+	}
+	return false
+}
+
+// sendExpecting sends req, which carries Expect: 100-continue, writing to w
+// and reading from r, and reads the response. Only the head is sent at
+// first. The body follows once the server answers 100 Continue, or when it
+// has not answered within --expect100-timeout. A final status instead is
+// the response, and the body is never sent.
+func (t *transfer) sendExpecting(ctx context.Context, conn net.Conn, w io.Writer, r io.Reader, req *request, meter *progressMeter) ([]byte, error) {
+	if _, err := w.Write(req.head()); err != nil {
+		return nil, err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(seconds(t.Expect100Timeout)))
+	head, err := readHead(r, t.maxHeadersSize)
+	deadline, _ := ctx.Deadline()
+	conn.SetReadDeadline(deadline)
+	var netErr net.Error
+	timedOut := errors.As(err, &netErr) && netErr.Timeout() && ctx.Err() == nil
+	if err != nil && !timedOut {
+		return nil, err
+	}
+
+	if !timedOut && !bytes.HasPrefix(statusCode(head), []byte("100")) {
+		req.bodySkipped = true
+		return t.readResponse(io.MultiReader(bytes.NewReader(head), r), req.method, meter)
+	}
+
+	if timedOut {
+		if t.Verbose {
+			t.infof("Done waiting for 100-continue")
+		}
+	} else {
+		if t.Verbose {
+			t.dumpLines("< ", head)
+		}
+		head = nil
+	}
+	bw := bufio.NewWriter(w)
+	if err := req.writeBody(bw); err != nil {
+		return nil, err
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, err
+	}
+	return t.readResponse(io.MultiReader(bytes.NewReader(head), r), req.method, meter)
+}
+
+// readHead reads from r up to the end of a response header block, failing
+// with errHeaderSize once it is over maxSize bytes, unless that is 0. What
+// was read is returned even on error.
+func readHead(r io.Reader, maxSize int64) ([]byte, error) {
+	var head []byte
+	buf := make([]byte, 1)
+	for !bytes.HasSuffix(head, []byte("\r\n\r\n")) {
+		n, err := r.Read(buf)
+		head = append(head, buf[:n]...)
+		if err != nil {
+			return head, err
+		}
+		if maxSize > 0 && int64(len(head)) > maxSize {
+			return head, errHeaderSize
+		}
+	}
+	return head, nil
+}
+
+// statusCode returns the status code field of the status line at the
+// start of head.
+func statusCode(head []byte) []byte {
+	line, _, _ := bytes.Cut(head, []byte("\r\n"))
+	_, rest, _ := bytes.Cut(line, []byte(" "))
+	code, _, _ := bytes.Cut(rest, []byte(" "))
+	return code
+}
@@ -0,0 +1,113 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"path/filepath"
+	"strings"
+)
+
+// formPart is one -F field: a plain value, or a file to upload.
+type formPart struct {
+	name        string
+	value       string
+	file        string // path of the file to send, for name=@file
+	filename    string // file name sent to the server
+	contentType string
+}
+
+// FormArg is a -F or --form-string argument.
+type FormArg struct {
+	Value   string
+	Literal bool // whether it is from --form-string, with no @file meaning
+}
+
+// parseFormString parses a --form-string argument, "name=value", whose
+// value is sent as it is even when it starts with @.
+func parseFormString(s string) (formPart, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return formPart{}, fmt.Errorf("invalid --form-string %q: expected name=value", s)
+	}
+	return formPart{name: name, value: value}, nil
+}
+
+// parseFormPart parses a -F argument: "name=value", or "name=@file"
+// followed by optional ";type=..." and ";filename=..." modifiers.
+func parseFormPart(s string) (formPart, error) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return formPart{}, fmt.Errorf("invalid --form %q: expected name=value or name=@file", s)
+	}
+	if !strings.HasPrefix(value, "@") {
+		return formPart{name: name, value: value}, nil
+	}
+
+	fields := strings.Split(value[1:], ";")
+	p := formPart{name: name, file: fields[0], filename: filepath.Base(fields[0])}
+	for _, f := range fields[1:] {
+		key, v, _ := strings.Cut(strings.TrimSpace(f), "=")
+		switch strings.ToLower(key) {
+		case "type":
+			p.contentType = v
+		case "filename":
+			p.filename = v
+		default:
+			return formPart{}, fmt.Errorf("invalid --form %q: unknown modifier %q", s, key)
+		}
+	}
+	if p.contentType == "" {
+		p.contentType = mime.TypeByExtension(filepath.Ext(p.file))
+	}
+	if p.contentType == "" {
+		p.contentType = "application/octet-stream"
+	}
+	return p, nil
+}
+
+// quoteEscaper escapes a Content-Disposition parameter value, as
+// mime/multipart does.
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// buildForm encodes the -F and --form-string fields as a
+// multipart/form-data body, returning it with its Content-Type.
+func buildForm(args []FormArg) ([]byte, string, error) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	for _, arg := range args {
+		parse := parseFormPart
+		if arg.Literal {
+			parse = parseFormString
+		}
+		p, err := parse(arg.Value)
+		if err != nil {
+			return nil, "", err
+		}
+		if p.file == "" {
+			if err := w.WriteField(p.name, p.value); err != nil {
+				return nil, "", err
+			}
+			continue
+		}
+
+		data, err := ReadDataFile(p.file)
+		if err != nil {
+			return nil, "", err
+		}
+		h := make(textproto.MIMEHeader)
+		h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, quoteEscaper.Replace(p.name), quoteEscaper.Replace(p.filename)))
+		h.Set("Content-Type", p.contentType)
+		part, err := w.CreatePart(h)
+		if err != nil {
+			return nil, "", err
+		}
+		part.Write(data)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return b.Bytes(), w.FormDataContentType(), nil
+}
@@ -0,0 +1,195 @@
+package curl
+
+import (
+	"fmt"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+// globURL is one URL produced by expanding the globs in a command-line URL,
+// with the text each glob matched, for the #1, #2, ... placeholders of -o.
+type globURL struct {
+	url     string
+	matches []string
+}
+
+// maxGlobURLs bounds how many URLs one glob may expand to.
+const maxGlobURLs = 100000
+
+// expandGlob expands the [start-end:step] ranges and {a,b,c} lists in s
+// into every URL they describe, the last glob varying fastest. A bracketed
+// IPv6 address as the host is not a range, and a \[, \], \{ or \} stands
+// for the character itself.
+func expandGlob(s string) ([]globURL, error) {
+	var parts [][]string // each literal is a part with a single alternative
+	var isGlob []bool
+	var lit strings.Builder
+	hostEnd := hostEnd(s)
+	for i := 0; i < len(s); {
+		var close byte
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) && strings.IndexByte("[]{}", s[i+1]) >= 0 {
+				i++
+			}
+			lit.WriteByte(s[i])
+			i++
+			continue
+		case '{':
+			close = '}'
+		case '[':
+			if n := ipv6Literal(s[i:]); n > 0 && i < hostEnd {
+				lit.WriteString(s[i : i+n])
+				i += n
+				continue
+			}
+			close = ']'
+		default:
+			j := strings.IndexAny(s[i:], "{[\\")
+			if j < 0 {
+				j = len(s) - i
+			}
+			lit.WriteString(s[i : i+j])
+			i += j
+			continue
+		}
+		if lit.Len() > 0 {
+			parts = append(parts, []string{lit.String()})
+			isGlob = append(isGlob, false)
+			lit.Reset()
+		}
+
+		end := strings.IndexByte(s[i+1:], close)
+		if end < 0 {
+			return nil, exitErrorf(exitURLMalformat, "bad range in URL position %d: unmatched %q", i+1, s[i])
+		}
+		body := s[i+1 : i+1+end]
+		var alts []string
+		var err error
+		if close == '}' {
+			alts = strings.Split(body, ",")
+		} else if alts, err = globRange(body); err != nil {
+			return nil, exitErrorf(exitURLMalformat, "bad range in URL position %d: %w", i+1, err)
+		}
+		parts = append(parts, alts)
+		isGlob = append(isGlob, true)
+		i += end + 2
+	}
+	if lit.Len() > 0 {
+		parts = append(parts, []string{lit.String()})
+		isGlob = append(isGlob, false)
+	}
+
+	urls := []globURL{{}}
+	for p, alts := range parts {
+		if len(urls)*len(alts) > maxGlobURLs {
+			return nil, exitErrorf(exitURLMalformat, "too many URLs in glob %q", s)
+		}
+		next := make([]globURL, 0, len(urls)*len(alts))
+		for _, g := range urls {
+			for _, alt := range alts {
+				n := globURL{url: g.url + alt, matches: g.matches}
+				if isGlob[p] {
+					n.matches = append(append([]string(nil), g.matches...), alt)
+				}
+				next = append(next, n)
+			}
+		}
+		urls = next
+	}
+	return urls, nil
+}
+
+// hostEnd returns the offset in the URL s at which its host and port end.
+func hostEnd(s string) int {
+	start := schemeLen(s)
+	if end := strings.IndexAny(s[start:], "/?#"); end >= 0 {
+		return start + end
+	}
+	return len(s)
+}
+
+// ipv6Literal returns the length of the bracketed IPv6 address, zone
+// included, that s starts with, or 0 if it does not start with one.
+func ipv6Literal(s string) int {
+	end := strings.IndexByte(s, ']')
+	if end < 0 {
+		return 0
+	}
+	addr, _, _ := strings.Cut(s[1:end], "%") // drop the zone
+	if ip, err := netip.ParseAddr(addr); err != nil || !ip.Is6() {
+		return 0
+	}
+	return end + 1
+}
+
+// globRange expands the body of a [start-end:step] glob: a range of numbers,
+// zero-padded like start when it has leading zeros, or of letters.
+func globRange(body string) ([]string, error) {
+	spec, stepText, hasStep := strings.Cut(body, ":")
+	step := 1
+	if hasStep {
+		var err error
+		if step, err = strconv.Atoi(stepText); err != nil || step < 1 {
+			return nil, fmt.Errorf("invalid step %q", stepText)
+		}
+	}
+	first, last, ok := strings.Cut(spec, "-")
+	if !ok {
+		return nil, fmt.Errorf("expected [start-end] but got [%s]", body)
+	}
+
+	var alts []string
+	if len(first) == 1 && len(last) == 1 && isLetter(first[0]) && isLetter(last[0]) {
+		if first[0] > last[0] || isLower(first[0]) != isLower(last[0]) {
+			return nil, fmt.Errorf("invalid letter range [%s]", spec)
+		}
+		for c := int(first[0]); c <= int(last[0]); c += step {
+			alts = append(alts, string(rune(c)))
+		}
+		return alts, nil
+	}
+
+	from, err1 := strconv.Atoi(first)
+	to, err2 := strconv.Atoi(last)
+	if err1 != nil || err2 != nil || from < 0 || from > to {
+		return nil, fmt.Errorf("invalid range [%s]", spec)
+	}
+	if to-from >= maxGlobURLs*step {
+		return nil, fmt.Errorf("range [%s] is too large", spec)
+	}
+	width := 0
+	if len(first) > 1 && first[0] == '0' {
+		width = len(first)
+	}
+	for n := from; n <= to; n += step {
+		alts = append(alts, fmt.Sprintf("%0*d", width, n))
+	}
+	return alts, nil
+}
+
+func isLetter(c byte) bool { return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' }
+
+func isLower(c byte) bool { return 'a' <= c && c <= 'z' }
+
+// globOutput replaces the #1, #2, ... placeholders in an -o file name with
+// the text matched by the corresponding glob.
+func globOutput(name string, matches []string) string {
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] == '#' && i+1 < len(name) && '1' <= name[i+1] && name[i+1] <= '9' {
+			j := i + 1
+			for j < len(name) && '0' <= name[j] && name[j] <= '9' {
+				j++
+			}
+			if n, _ := strconv.Atoi(name[i+1 : j]); n <= len(matches) {
+				b.WriteString(matches[n-1])
+				i = j - 1
+				continue
+			}
+		}
+		b.WriteByte(name[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package curl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandGlob(t *testing.T) {
+	tests := []struct {
+		s    string
+		want []string
+	}{
+		{"http://h/[1-3]", []string{"http://h/1", "http://h/2", "http://h/3"}},
+		{"http://h/{a,b}", []string{"http://h/a", "http://h/b"}},
+		{"http://[::1]:8080/[1-2]", []string{"http://[::1]:8080/1", "http://[::1]:8080/2"}},
+		{"[::1]/a", []string{"[::1]/a"}},
+		{"http://[fe80::1%25eth0]/", []string{"http://[fe80::1%25eth0]/"}},
+		{`http://h/\[1-2\]`, []string{"http://h/[1-2]"}},
+		{`http://h/\{a,b\}`, []string{"http://h/{a,b}"}},
+		{`http://h/a\b`, []string{`http://h/a\b`}},
+	}
+	for _, tt := range tests {
+		got, err := expandGlob(tt.s)
+		if err != nil {
+			t.Errorf("expandGlob(%q): %v", tt.s, err)
+			continue
+		}
+		var urls []string
+		for _, g := range got {
+			urls = append(urls, g.url)
+		}
+		if !slices.Equal(urls, tt.want) {
+			t.Errorf("expandGlob(%q) = %q, want %q", tt.s, urls, tt.want)
+		}
+	}
+}
+
+func TestExpandGlobIPv6PathIsRange(t *testing.T) {
+	// Only the host is taken as an address; in the path it is a range.
+	if _, err := expandGlob("http://h/[::1]"); err == nil {
+		t.Error("expandGlob of [::1] in the path succeeded, want a bad range")
+	}
+}
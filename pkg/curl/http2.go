@@ -0,0 +1,275 @@
+package curl
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// h2Stream is the stream a request is sent on; there is only ever one per
+// connection.
+const h2Stream = 1
+
+// h2ConnectionHeaders are the HTTP/1.1 connection-specific headers that must
+// not be sent over HTTP/2.
+var h2ConnectionHeaders = map[string]bool{
+	"connection":        true,
+	"keep-alive":        true,
+	"proxy-connection":  true,
+	"transfer-encoding": true,
+	"upgrade":           true,
+}
+
+// isH2 reports whether conn is a TLS connection on which the server agreed
+// to HTTP/2 through ALPN.
+func isH2(conn net.Conn) bool {
+	tlsConn, ok := conn.(*tls.Conn)
+	return ok && tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS
+}
+
+// toH2 adapts req to be sent over HTTP/2, dropping the connection-specific
+// headers. Host is kept, to be sent as the :authority pseudo-header. Expect
+// goes too, as the body is sent without waiting for a 100 Continue.
+func toH2(req *request) {
+	req.proto = "HTTP/2"
+	headers := req.headers[:0]
+	for _, h := range req.headers {
+		if name := strings.ToLower(h.name); !h2ConnectionHeaders[name] && name != "expect" {
+			headers = append(headers, h)
+		}
+	}
+	req.headers = headers
+}
+
+// h2RoundTrip sends req for u over an HTTP/2 connection, writing to w and
+// reading from r, and reads the response. The response is returned in
+// HTTP/1.1 form, status line and headers followed by the body, so that it
+// can be parsed and shown like any other.
+func h2RoundTrip(r io.Reader, w io.Writer, req *request, u *url.URL, maxHeaders int64, meter *progressMeter, stream *bodyStream) ([]byte, error) {
+	body := req.body
+	if req.upload != nil {
+		upload, err := io.ReadAll(req.upload)
+		if err != nil {
+			return nil, err
+		}
+		body = append(append([]byte(nil), body...), upload...)
+	}
+
+	if _, err := io.WriteString(w, http2.ClientPreface); err != nil {
+		return nil, err
+	}
+	fr := http2.NewFramer(w, r)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	if err := fr.WriteSettings(); err != nil {
+		return nil, err
+	}
+
+	var block bytes.Buffer
+	enc := hpack.NewEncoder(&block)
+	enc.WriteField(hpack.HeaderField{Name: ":method", Value: req.method})
+	enc.WriteField(hpack.HeaderField{Name: ":scheme", Value: u.Scheme})
+	enc.WriteField(hpack.HeaderField{Name: ":authority", Value: h2Authority(req, u)})
+	enc.WriteField(hpack.HeaderField{Name: ":path", Value: req.target})
+	for _, h := range req.headers {
+		if name := strings.ToLower(h.name); name != "host" {
+			enc.WriteField(hpack.HeaderField{Name: name, Value: h.value})
+		}
+	}
+	if err := fr.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      h2Stream,
+		BlockFragment: block.Bytes(),
+		EndStream:     len(body) == 0,
+		EndHeaders:    true,
+	}); err != nil {
+		return nil, err
+	}
+
+	s := &h2Exchange{fr: fr, connWindow: h2DefaultWindow, streamWindow: h2DefaultWindow, initialWindow: h2DefaultWindow, maxFrame: 16384, maxHeaders: maxHeaders, meter: meter, stream: stream}
+	if err := s.sendBody(body); err != nil {
+		return nil, err
+	}
+	for !s.done {
+		if err := s.readFrame(); err != nil {
+			return nil, err
+		}
+	}
+	return append(s.head.Bytes(), s.body...), nil
+}
+
+// h2Authority returns the :authority to send for req: the Host header,
+// which -H may have changed, or the host of u if there is none.
+func h2Authority(req *request, u *url.URL) string {
+	for _, h := range req.headers {
+		if strings.EqualFold(h.name, "Host") {
+			return h.value
+		}
+	}
+	return u.Host
+}
+
+// h2DefaultWindow is the flow-control window that both the connection and
+// a stream start with, before any SETTINGS or WINDOW_UPDATE.
+const h2DefaultWindow = 65535
+
+// h2Exchange is the state of a request and response on an HTTP/2
+// connection.
+type h2Exchange struct {
+	fr *http2.Framer
+
+	// The server accepts DATA up to the smaller of two flow-control
+	// windows: that of the connection, opened by WINDOW_UPDATE on stream
+	// 0, and that of the stream, which starts at the initial window size
+	// the server's SETTINGS declare.
+	connWindow    int64
+	streamWindow  int64
+	initialWindow int64
+
+	maxFrame   uint32 // largest frame payload the server accepts
+	maxHeaders int64  // --max-headers-size, or 0 for no limit
+	meter      *progressMeter
+	stream     *bodyStream
+
+	head bytes.Buffer // response status line and headers, HTTP/1.1 style
+	body []byte
+	done bool // whether the server has ended the stream
+}
+
+// sendBody sends the request body in DATA frames, waiting for the server to
+// open its flow-control window whenever it fills.
+func (s *h2Exchange) sendBody(body []byte) error {
+	for len(body) > 0 {
+		window := min(s.connWindow, s.streamWindow)
+		if window <= 0 {
+			if err := s.readFrame(); err != nil {
+				return err
+			}
+			if s.done {
+				return nil
+			}
+			continue
+		}
+		n := min(int64(len(body)), window, int64(s.maxFrame))
+		if err := s.fr.WriteData(h2Stream, int64(len(body)) == n, body[:n]); err != nil {
+			return err
+		}
+		s.connWindow -= n
+		s.streamWindow -= n
+		body = body[n:]
+	}
+	return nil
+}
+
+// readFrame reads and handles one frame from the server.
+func (s *h2Exchange) readFrame() error {
+	f, err := s.fr.ReadFrame()
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		// A timeout, left for Classify to report as one.
+		return err
+	}
+	if err != nil {
+		return exitErrorf(exitRecv, "HTTP/2 framing error: %w", err)
+	}
+
+	switch f := f.(type) {
+	case *http2.SettingsFrame:
+		if f.IsAck() {
+			return nil
+		}
+		f.ForeachSetting(func(setting http2.Setting) error {
+			switch setting.ID {
+			case http2.SettingInitialWindowSize:
+				// Only the stream window follows it, by the change.
+				s.streamWindow += int64(setting.Val) - s.initialWindow
+				s.initialWindow = int64(setting.Val)
+			case http2.SettingMaxFrameSize:
+				s.maxFrame = setting.Val
+			}
+			return nil
+		})
+		return s.fr.WriteSettingsAck()
+	case *http2.WindowUpdateFrame:
+		switch f.StreamID {
+		case 0:
+			s.connWindow += int64(f.Increment)
+		case h2Stream:
+			s.streamWindow += int64(f.Increment)
+		}
+	case *http2.PingFrame:
+		if !f.IsAck() {
+			return s.fr.WritePing(true, f.Data)
+		}
+	case *http2.MetaHeadersFrame:
+		if f.Truncated {
+			return errHeaderSize
+		}
+		if s.head.Len() == 0 {
+			if err := s.writeHead(f); err != nil {
+				return err
+			}
+			if s.head.Len() > 0 {
+				if _, err := s.stream.feed(s.head.Bytes()); err != nil {
+					return err
+				}
+			}
+		}
+		s.done = f.StreamEnded()
+	case *http2.DataFrame:
+		s.body = append(s.body, f.Data()...)
+		raw := append(s.head.Bytes(), s.body...)
+		s.meter.update(raw)
+		complete, err := s.stream.feed(raw)
+		if err != nil {
+			return err
+		}
+		if n := uint32(len(f.Data())); n > 0 {
+			// Give back the window at once, as the whole body is kept
+			// anyway.
+			s.fr.WriteWindowUpdate(0, n)
+			s.fr.WriteWindowUpdate(h2Stream, n)
+		}
+		// With --head-bytes the stream may have all it wants before the
+		// server is done.
+		s.done = f.StreamEnded() || complete
+	case *http2.RSTStreamFrame:
+		return exitErrorf(exitRecv, "HTTP/2 stream was reset: %v", f.ErrCode)
+	case *http2.GoAwayFrame:
+		if f.ErrCode != http2.ErrCodeNo || f.LastStreamID < h2Stream {
+			return exitErrorf(exitRecv, "HTTP/2 server went away: %v", f.ErrCode)
+		}
+	}
+	return nil
+}
+
+// writeHead renders a HEADERS frame as an HTTP/1.1-style status line and
+// header block. Informational 1xx heads are replaced by the final one, and
+// trailers are ignored. A block rendered larger than --max-headers-size
+// fails with errHeaderSize, as it does over HTTP/1.1.
+func (s *h2Exchange) writeHead(f *http2.MetaHeadersFrame) error {
+	status := f.PseudoValue("status")
+	if status == "" {
+		return nil
+	}
+	if code, err := strconv.Atoi(status); err == nil && code < 200 {
+		return nil
+	}
+	s.head.Reset()
+	fmt.Fprintf(&s.head, "HTTP/2 %s\r\n", status)
+	for _, h := range f.RegularFields() {
+		fmt.Fprintf(&s.head, "%s: %s\r\n", h.Name, h.Value)
+	}
+	s.head.WriteString("\r\n")
+	if s.maxHeaders > 0 && int64(s.head.Len()) > s.maxHeaders {
+		return errHeaderSize
+	}
+	return nil
+}
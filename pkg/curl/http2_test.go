@@ -0,0 +1,85 @@
+package curl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// h2Server starts a TLS server speaking HTTP/2 with handler, and returns
+// it with options that reach it over HTTP/2.
+func h2Server(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *Options) {
+	t.Helper()
+	srv := httptest.NewUnstartedServer(handler)
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	o := DefaultOptions()
+	o.HTTP2, o.Insecure, o.Silent = true, true, true
+	return srv, &o
+}
+
+func TestH2MaxHeadersSize(t *testing.T) {
+	srv, o := h2Server(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/big" {
+			w.Header().Set("X-Big", strings.Repeat("a", 2000))
+		}
+		w.Write([]byte("ok"))
+	})
+	o.MaxHeadersSize = "1k"
+	c := &Client{Options: o}
+	defer c.CloseIdleConnections()
+
+	resp, err := c.Do(context.Background(), &Request{URL: srv.URL + "/small"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Proto != "HTTP/2" || string(resp.Body) != "ok" {
+		t.Errorf("got %s %q, want HTTP/2 \"ok\"", resp.Proto, resp.Body)
+	}
+
+	_, err = c.Do(context.Background(), &Request{URL: srv.URL + "/big"})
+	if code := Classify(err).Code; err == nil || code != exitTooLarge {
+		t.Errorf("2 KB of headers over a 1k limit: err = %v, want exit code %d", err, exitTooLarge)
+	}
+}
+
+func TestH2AuthorityFromHostHeader(t *testing.T) {
+	srv, o := h2Server(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host))
+	})
+	c := &Client{Options: o}
+	defer c.CloseIdleConnections()
+
+	resp, err := c.Do(context.Background(), &Request{URL: srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.TrimPrefix(srv.URL, "https://"); string(resp.Body) != want {
+		t.Errorf(":authority = %q, want %q", resp.Body, want)
+	}
+
+	resp, err = c.Do(context.Background(), &Request{URL: srv.URL, Header: http.Header{"Host": {"example.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "example.com" {
+		t.Errorf("with -H Host, :authority = %q, want %q", resp.Body, "example.com")
+	}
+}
+
+func TestH2Timeout(t *testing.T) {
+	srv, o := h2Server(t, func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+	c := &Client{Options: o, Timeout: 200 * time.Millisecond}
+	defer c.CloseIdleConnections()
+
+	_, err := c.Do(context.Background(), &Request{URL: srv.URL})
+	if code := Classify(err).Code; err == nil || code != exitTimeout {
+		t.Errorf("err = %v (exit code %d), want exit code %d", err, code, exitTimeout)
+	}
+}
@@ -0,0 +1,125 @@
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// netrcEntry holds the credentials of one machine in a .netrc file. The
+// default entry has an empty machine name.
+type netrcEntry struct {
+	machine  string
+	login    string
+	password string
+}
+
+// netrc is the list of entries read from a .netrc file.
+type netrc []netrcEntry
+
+// loadNetrc reads the netrc file chosen by -n or --netrc-file. Without
+// --netrc-file a missing ~/.netrc is not an error.
+func loadNetrc(o *Options) (netrc, error) {
+	name := o.NetrcFile
+	if name == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.Join(home, ".netrc")
+	}
+
+	b, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) && o.NetrcFile == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, exitErrorf(exitRead, "Couldn't read netrc file: %w", err)
+	}
+	if fi, err := os.Stat(name); err == nil && fi.Mode().Perm()&0o077 != 0 {
+		o.warnf("%s is accessible by other users; it should have mode 0600", name)
+	}
+
+	entries, err := parseNetrc(string(b))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return entries, nil
+}
+
+// parseNetrc parses the machine, default, login and password tokens of a
+// .netrc file. Macro definitions are skipped.
+func parseNetrc(s string) (netrc, error) {
+	var entries netrc
+	var cur *netrcEntry
+	lines := strings.Split(s, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		tokens := strings.Fields(line)
+		for j := 0; j < len(tokens); j++ {
+			tok := tokens[j]
+			switch tok {
+			case "default":
+				entries = append(entries, netrcEntry{})
+				cur = &entries[len(entries)-1]
+				continue
+			case "macdef":
+				// A macro runs until the next empty line.
+				for i++; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+				}
+				j = len(tokens)
+				continue
+			}
+
+			if j+1 >= len(tokens) {
+				return nil, fmt.Errorf("netrc token %q without a value", tok)
+			}
+			j++
+			value := tokens[j]
+			switch tok {
+			case "machine":
+				entries = append(entries, netrcEntry{machine: value})
+				cur = &entries[len(entries)-1]
+			case "login", "password", "account":
+				if cur == nil {
+					return nil, fmt.Errorf("netrc token %q before any machine", tok)
+				}
+				if tok == "login" {
+					cur.login = value
+				} else if tok == "password" {
+					cur.password = value
+				}
+			default:
+				return nil, fmt.Errorf("unknown netrc token %q", tok)
+			}
+		}
+	}
+	return entries, nil
+}
+
+// credentials returns the "login:password" of the entry for host, or of the
+// default entry if there is none.
+func (n netrc) credentials(host string) (string, bool) {
+	var def *netrcEntry
+	for i, e := range n {
+		if e.machine == "" {
+			if def == nil {
+				def = &n[i]
+			}
+			continue
+		}
+		if strings.EqualFold(e.machine, host) {
+			return e.login + ":" + e.password, true
+		}
+	}
+	if def != nil {
+		return def.login + ":" + def.password, true
+	}
+	return "", false
+}
@@ -0,0 +1,262 @@
+package curl
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"os"
+)
+
+// Options holds the settings that shape a transfer. Each field is set by
+// the command-line flag of much the same name: Location by -L, --location,
+// MaxHeadersSize by --max-headers-size and so on. DefaultOptions returns
+// the settings the flags default to.
+type Options struct {
+	Method              string
+	Headers             []string
+	Data                []DataArg
+	Output              []string
+	DumpHeader          string
+	DumpHeaderFinal     bool
+	HeadersJSON         string
+	OutputDir           string
+	Trace               string
+	TraceASCII          string
+	CreateDirs          bool
+	AppendOutput        bool
+	RemoteTime          bool
+	EtagSave            string
+	EtagCompare         string
+	TimeCond            string
+	Pretty              bool
+	DryRun              bool
+	PathAsIs            bool
+	RequestTarget       string
+	RemoteName          bool
+	Verbose             bool
+	StderrFile          string
+	HTTP10              bool
+	Compressed          bool
+	Raw                 bool
+	Include             bool
+	Head                bool
+	Location            bool
+	LocationTrusted     bool
+	MaxRedirs           int
+	Post301             bool
+	Post302             bool
+	Post303             bool
+	User                string
+	UserAgent           string
+	Referer             string
+	Cookie              string
+	CookieJar           string
+	Fail                bool
+	FailWithBody        bool
+	WriteOut            string
+	Silent              bool
+	ShowError           bool
+	NoProgressMeter     bool
+	LimitRate           string
+	MaxFilesize         string
+	HeadBytes           int64
+	MaxHeadersSize      string
+	IgnoreContentLength bool
+	ContinueAt          string
+	ByteRange           string
+	Insecure            bool
+	CACert              string
+	Cert                string
+	Key                 string
+	TLSv10              bool
+	TLSv11              bool
+	TLSv12              bool
+	TLSv13              bool
+	TLSMax              string
+	SNI                 string
+	ALPN                string
+	NoALPN              bool
+	Resolve             []string
+	ConnectTo           []string
+	IPv4                bool
+	IPv6                bool
+	UnixSocket          string
+	AbstractSocket      string
+	Interface           string
+	DoHURL              string
+	DNSServers          string
+	ResolveTimeout      float64
+	LocalPort           string
+	Proxy               string
+	Retry               int
+	RetryAllErrors      bool
+	RetryDelay          float64
+	RetryMaxTime        float64
+	GlobOff             bool
+	Get                 bool
+	Queries             []string
+	Upload              string
+	Forms               []FormArg
+	Digest              bool
+	Bearer              string
+	Netrc               bool
+	NetrcFile           string
+	HTTP2               bool
+	Parallel            bool
+	ParallelMax         int
+	NoKeepalive         bool
+	KeepaliveTime       int
+	NoBuffer            bool
+	NoChunked           bool
+
+	SpeedLimit           int64
+	SpeedTime            int
+	HappyEyeballsTimeout int
+	Expect100Timeout     float64
+	ConnectTimeout       float64
+	MaxTime              float64
+
+	// errOut is where diagnostics go in place of stderr, once --stderr
+	// is open, or nil for stderr itself.
+	errOut io.Writer
+}
+
+// DefaultOptions returns the options the command-line flags default to.
+func DefaultOptions() Options {
+	return Options{
+		UserAgent:            defaultUserAgent,
+		MaxRedirs:            50,
+		MaxHeadersSize:       "100k",
+		ParallelMax:          defaultParallelMax,
+		Expect100Timeout:     defaultExpect100Timeout,
+		HappyEyeballsTimeout: defaultHappyEyeballsTimeout,
+		KeepaliveTime:        defaultKeepaliveTime,
+	}
+}
+
+// RequestMethod returns the method to send: the one given with -X, HEAD
+// with -I, PUT with -T, POST when there is request data or a form to send as
+// the body and GET otherwise.
+func (o *Options) RequestMethod() string {
+	if o.Method != "" {
+		return o.Method
+	}
+	if o.Head {
+		return "HEAD"
+	}
+	if o.Upload != "" {
+		return "PUT"
+	}
+	if len(o.Data) > 0 && !o.Get || len(o.Forms) > 0 {
+		return "POST"
+	}
+	return "GET"
+}
+
+// proto returns the HTTP version to put on the request line.
+func (o *Options) proto() string {
+	if o.HTTP10 {
+		return "HTTP/1.0"
+	}
+	return "HTTP/1.1"
+}
+
+// cookieEngine reports whether cookies received are kept for later
+// requests, which curl does once -b or -c is given.
+func (o *Options) cookieEngine() bool {
+	return o.Cookie != "" || o.CookieJar != ""
+}
+
+// decodes reports whether a body with the Content-Encoding named by
+// encoding is decoded: with --compressed, which asked for it, or for
+// brotli, and never with --raw.
+func (o *Options) decodes(encoding string) bool {
+	return !o.Raw && (o.Compressed || isBrotli(encoding))
+}
+
+// stderr returns where error messages, warnings, -v output and the
+// progress meter go: the --stderr file, or the process stderr.
+func (o *Options) stderr() io.Writer {
+	if o.errOut != nil {
+		return o.errOut
+	}
+	return os.Stderr
+}
+
+// showErrors reports whether error messages and warnings go to stderr.
+func (o *Options) showErrors() bool {
+	return !o.Silent || o.ShowError
+}
+
+// showProgress reports whether the progress meter should be drawn for a
+// body written to the file called output. The meter is left out when the
+// body goes to a terminal, where the two would garble each other, and with
+// -N, which writes the body as it arrives, and --no-progress-meter turns it
+// off without silencing anything else.
+func (o *Options) showProgress(output string) bool {
+	if o.Silent || o.NoProgressMeter || o.NoBuffer {
+		return false
+	}
+	if output != "" {
+		return true
+	}
+	fi, err := os.Stdout.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice == 0
+}
+
+// traceFile returns the file named by --trace or --trace-ascii, if any, and
+// whether it is the ASCII-only one.
+func (o *Options) traceFile() (name string, ascii bool) {
+	if o.TraceASCII != "" {
+		return o.TraceASCII, true
+	}
+	return o.Trace, false
+}
+
+// failEarly reports whether the first failed transfer stops the rest from
+// being started, as it does with -f and --fail-with-body.
+func (o *Options) failEarly() bool {
+	return o.Fail || o.FailWithBody
+}
+
+// keepMethod reports whether a redirect with status code keeps the method
+// and body of the request, as --post301, --post302 and --post303 ask for.
+func (o *Options) keepMethod(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently:
+		return o.Post301
+	case http.StatusFound:
+		return o.Post302
+	case http.StatusSeeOther:
+		return o.Post303
+	}
+	return false
+}
+
+// network returns the network to dial: "tcp4" or "tcp6" when -4 or -6
+// restricts the address family, and "tcp" otherwise.
+func (o *Options) network() string {
+	switch {
+	case o.IPv4:
+		return "tcp4"
+	case o.IPv6:
+		return "tcp6"
+	}
+	return "tcp"
+}
+
+// tlsMin returns the minimum TLS version asked for with the --tlsv1.x flags,
+// taking the highest if several are given, or 0 for the crypto/tls default.
+func (o *Options) tlsMin() uint16 {
+	switch {
+	case o.TLSv13:
+		return tls.VersionTLS13
+	case o.TLSv12:
+		return tls.VersionTLS12
+	case o.TLSv11:
+		return tls.VersionTLS11
+	case o.TLSv10:
+		return tls.VersionTLS10
+	}
+	return 0
+}
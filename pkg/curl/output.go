@@ -0,0 +1,230 @@
+package curl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// outputName returns the file the body of u, expanded from the URL at index
+// i on the command line, should be saved to: the i-th -o file with its #N
+// placeholders replaced by the glob matches, the last path segment of u
+// under -O, or "" for stdout. A relative name is placed in --output-dir.
+func outputName(o *Options, u *url.URL, i int, matches []string) (string, error) {
+	var name string
+	switch {
+	case i < len(o.Output):
+		name = globOutput(o.Output[i], matches)
+	case !o.RemoteName:
+		return "", nil
+	case u.Path == "" || strings.HasSuffix(u.Path, "/"):
+		return "", exitErrorf(exitWrite, "Remote file name has no length: -O needs a URL that ends in a file name")
+	default:
+		name = path.Base(u.Path)
+	}
+
+	if o.OutputDir != "" && !filepath.IsAbs(name) {
+		name = filepath.Join(o.OutputDir, name)
+	}
+	return name, nil
+}
+
+// writeBody writes the response body to the file called name, or to stdout
+// when name is empty. The file is appended to when appendTo is set, and
+// truncated otherwise.
+func writeBody(name string, body []byte, appendTo bool) error {
+	if name == "" {
+		if _, err := os.Stdout.Write(body); err != nil {
+			return exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+		}
+		return nil
+	}
+	return saveFile(name, body, appendTo)
+}
+
+// saveFile writes body to the file called name, creating it if needed.
+func saveFile(name string, body []byte, appendTo bool) error {
+	f, err := openOutput(name, appendTo)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(body); err != nil {
+		return exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+	}
+	return nil
+}
+
+// openOutput opens the output file called name for writing, creating it if
+// needed. It is appended to when appendTo is set, and truncated otherwise.
+func openOutput(name string, appendTo bool) (*os.File, error) {
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if appendTo {
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	f, err := os.OpenFile(name, flag, 0o666)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, exitErrorf(exitWrite, "Failed to open %s: its directory does not exist (use --create-dirs to create it)", name)
+	}
+	if err != nil {
+		return nil, exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+	}
+	return f, nil
+}
+
+// createOutputDirs creates the directories of the output file for
+// --create-dirs.
+func (t *transfer) createOutputDirs() error {
+	if !t.CreateDirs || t.output == "" {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(t.output), 0o755); err != nil {
+		return exitErrorf(exitWrite, "Failed to create the directories of %s: %w", t.output, err)
+	}
+	return nil
+}
+
+// setRemoteTime sets the modification time of the file called name to
+// lastModified, a Last-Modified header value, for -R. A missing or invalid
+// value leaves the file as it is.
+func setRemoteTime(name, lastModified string) {
+	if mtime, err := http.ParseTime(lastModified); err == nil {
+		os.Chtimes(name, mtime, mtime)
+	}
+}
+
+// openStderr opens the --stderr file, or stdout for "-", as the place
+// diagnostics go from here on, and returns the function that closes it
+// again. Without --stderr there is nothing to open.
+func (o *Options) openStderr() (closeStderr func(), err error) {
+	switch o.StderrFile {
+	case "":
+		return func() {}, nil
+	case "-":
+		o.errOut = os.Stdout
+		return func() { o.errOut = nil }, nil
+	}
+	f, err := os.Create(o.StderrFile)
+	if err != nil {
+		return nil, exitErrorf(exitWrite, "Failed to open the --stderr file %s: %w", o.StderrFile, err)
+	}
+	o.errOut = f
+	return func() {
+		o.errOut = nil
+		f.Close()
+	}, nil
+}
+
+// headerDump is the -D or --output-headers-json destination, shared by all
+// the transfers of one invocation so that their headers follow one another
+// in the same file.
+type headerDump struct {
+	mu    sync.Mutex
+	w     io.Writer
+	f     *os.File // the file behind w, or nil for stdout
+	json  bool     // whether the final headers go as a JSON object instead
+	final bool     // whether only the final hop is written, for --dump-header-final
+}
+
+// openHeaderDump creates the -D file called name, or returns a dump to
+// stdout for "-".
+func openHeaderDump(name string) (*headerDump, error) {
+	if name == "-" {
+		return &headerDump{w: os.Stdout}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+	}
+	return &headerDump{w: f, f: f}, nil
+}
+
+// write appends the status line and headers of each of hops, in order, or
+// of only the final one for --dump-header-final, or for
+// --output-headers-json the headers of the final one as a JSON object on a
+// line of its own. A nil dump writes nothing.
+func (d *headerDump) write(hops []*Response) error {
+	if d == nil {
+		return nil
+	}
+	var b []byte
+	if d.json {
+		headers := hops[len(hops)-1].Headers
+		if headers == nil {
+			headers = map[string][]string{}
+		}
+		b, _ = json.Marshal(headers)
+		b = append(b, '\n')
+	} else {
+		if d.final {
+			hops = hops[len(hops)-1:]
+		}
+		for _, hop := range hops {
+			b = append(b, hop.rawHeader...)
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, err := d.w.Write(b); err != nil {
+		return exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+	}
+	return nil
+}
+
+func (d *headerDump) close() {
+	if d.f != nil {
+		d.f.Close()
+	}
+}
+
+// resumeOffset returns where -C arg resumes the download saved to output:
+// the size of the existing file for "-", or the given byte offset.
+func resumeOffset(arg, output string) (int64, error) {
+	if arg != "-" {
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid --continue-at offset %q", arg)
+		}
+		return n, nil
+	}
+
+	if output == "" {
+		return 0, errors.New("-C - needs -o or -O to know which file to resume")
+	}
+	fi, err := os.Stat(output)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// checkContentRange verifies that a 206 response's Content-Range header
+// starts at the offset we asked to resume from.
+func checkContentRange(value string, offset int64) error {
+	var start, end int64
+	if _, err := fmt.Sscanf(value, "bytes %d-%d/", &start, &end); err != nil {
+		return exitErrorf(exitRange, "Invalid Content-Range %q in partial response", value)
+	}
+	if start != offset {
+		return exitErrorf(exitRange, "Server resumed at byte %d instead of %d", start, offset)
+	}
+	return nil
+}
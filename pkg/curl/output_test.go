@@ -0,0 +1,95 @@
+package curl
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// scriptedServer answers each request on a listener of its own with the
+// raw response responses holds for its path, closing the connection
+// after it, and returns the listener's base URL.
+func scriptedServer(t *testing.T, responses map[string]string) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				tp := textproto.NewReader(bufio.NewReader(conn))
+				line, err := tp.ReadLine()
+				if err != nil {
+					return
+				}
+				if _, err := tp.ReadMIMEHeader(); err != nil {
+					return
+				}
+				fields := strings.Fields(line)
+				if len(fields) == 3 {
+					conn.Write([]byte(responses[fields[1]]))
+				}
+			}()
+		}
+	}()
+	return "http://" + ln.Addr().String()
+}
+
+func TestHeaderDumpRedirect(t *testing.T) {
+	const redirect = "HTTP/1.1 302 Found\r\n" +
+		"Location: /to\r\n" +
+		"Content-Length: 0\r\n" +
+		"\r\n"
+	const final = "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Length: 4\r\n" +
+		"\r\n"
+	base := scriptedServer(t, map[string]string{
+		"/from": redirect,
+		"/to":   final + "done",
+	})
+
+	tr := &transfer{Options: &Options{Location: true, MaxRedirs: 50}, jar: &cookieJar{}}
+	u, err := url.Parse(base + "/from")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hops, err := tr.follow(context.Background(), "GET", u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hops) != 2 {
+		t.Fatalf("%d hops, want 2", len(hops))
+	}
+
+	tests := []struct {
+		name  string
+		final bool
+		want  string
+	}{
+		{"every hop", false, redirect + final},
+		{"--dump-header-final", true, final},
+	}
+	for _, tt := range tests {
+		var b bytes.Buffer
+		d := &headerDump{w: &b, final: tt.final}
+		if err := d.write(hops); err != nil {
+			t.Fatal(err)
+		}
+		if b.String() != tt.want {
+			t.Errorf("%s: -D wrote %q, want %q", tt.name, b.String(), tt.want)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// defaultParallelMax is how many transfers -Z runs at once unless
+// --parallel-max says otherwise.
+const defaultParallelMax = 50
+
+// runParallel fetches jobs concurrently, at most --parallel-max at a time,
+// and returns the exit status of the last one to fail, or 0. With -f or
+// --fail-with-body no new transfer is started once one has failed.
+func runParallel(ctx context.Context, o *Options, s *session, jobs []job) int {
+	limit := o.ParallelMax
+	if limit < 1 {
+		limit = 1
+	}
+
+	var batch *batchProgress
+	if !o.Silent && !o.NoProgressMeter {
+		batch = startBatchProgress(len(jobs), o.stderr())
+		defer batch.stop()
+	}
+
+	var (
+		mu     sync.Mutex
+		status int
+		wg     sync.WaitGroup
+	)
+	sem := make(chan struct{}, limit)
+	for _, j := range jobs {
+		sem <- struct{}{}
+		mu.Lock()
+		failed := status != 0
+		mu.Unlock()
+		if failed && o.failEarly() || ctx.Err() != nil {
+			<-sem
+			break
+		}
+
+		wg.Add(1)
+		go func(j job) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			err := run(ctx, o, s, j.url, j.index, batch)
+			batch.finish()
+			if err != nil {
+				code := o.Report(err)
+				mu.Lock()
+				status = code
+				mu.Unlock()
+			}
+		}(j)
+	}
+	wg.Wait()
+	return status
+}
+
+// batchProgress draws a single progress line on stderr for all the
+// transfers of a -Z batch: how many are done and running, and the bytes
+// received by them together.
+type batchProgress struct {
+	w        io.Writer // stderr, or the --stderr file
+	mu       sync.Mutex
+	start    time.Time
+	total    int                     // transfers in the batch
+	finished int                     // transfers completed, successfully or not
+	meters   map[*progressMeter]bool // meters of the responses being read
+	received int64                   // body bytes of the responses already read
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startBatchProgress starts the meter for a batch of total transfers. All
+// batch methods accept a nil receiver.
+func startBatchProgress(total int, w io.Writer) *batchProgress {
+	b := &batchProgress{w: w, start: time.Now(), total: total, meters: make(map[*progressMeter]bool), done: make(chan struct{})}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				b.draw()
+			case <-b.done:
+				return
+			}
+		}
+	}()
+	return b
+}
+
+// add starts counting the response read through p.
+func (b *batchProgress) add(p *progressMeter) {
+	b.mu.Lock()
+	b.meters[p] = true
+	b.mu.Unlock()
+}
+
+// remove stops counting p, keeping the bytes it received in the total.
+func (b *batchProgress) remove(p *progressMeter) {
+	p.mu.Lock()
+	received := p.received
+	p.mu.Unlock()
+
+	b.mu.Lock()
+	delete(b.meters, p)
+	b.received += received
+	b.mu.Unlock()
+}
+
+// finish records that one transfer of the batch has completed.
+func (b *batchProgress) finish() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	b.finished++
+	b.mu.Unlock()
+}
+
+// stop halts the meter and draws its final state.
+func (b *batchProgress) stop() {
+	if b == nil {
+		return
+	}
+	close(b.done)
+	b.wg.Wait()
+	b.draw()
+	fmt.Fprintln(b.w)
+}
+
+func (b *batchProgress) draw() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	received := b.received
+	for p := range b.meters {
+		p.mu.Lock()
+		received += p.received
+		p.mu.Unlock()
+	}
+	elapsed := time.Since(b.start)
+	speed := int64(0)
+	if elapsed > 0 {
+		speed = int64(float64(received) / elapsed.Seconds())
+	}
+	line := fmt.Sprintf("%d/%d done  %d running  %8s received  %8s/s  %s elapsed",
+		b.finished, b.total, len(b.meters), formatBytes(received), formatBytes(speed), formatDuration(elapsed))
+	fmt.Fprintf(b.w, "\r%-*s", progressWidth, line)
+}
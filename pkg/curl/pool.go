@@ -0,0 +1,103 @@
+package curl
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// connPool holds the connections left open by HTTP/1.1 keep-alive, so that
+// a later request to the same scheme, host and port can reuse one instead of
+// dialing again. It is shared by the transfers that -Z runs at the same
+// time; a connection taken from the pool belongs to one request until it is
+// put back.
+type connPool struct {
+	mu   sync.Mutex
+	idle map[string][]net.Conn
+}
+
+func newConnPool() *connPool {
+	return &connPool{idle: make(map[string][]net.Conn)}
+}
+
+// poolKey returns the key of the connections that can serve requests to u.
+func poolKey(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+	return u.Scheme + "://" + net.JoinHostPort(strings.ToLower(u.Hostname()), port)
+}
+
+// get takes an idle connection for key out of the pool, or returns nil if
+// there is none. A nil pool never has one.
+func (p *connPool) get(key string) net.Conn {
+	if p == nil {
+		return nil
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	conns := p.idle[key]
+	if len(conns) == 0 {
+		return nil
+	}
+	conn := conns[len(conns)-1]
+	p.idle[key] = conns[:len(conns)-1]
+	return conn
+}
+
+// put returns conn to the pool once its response has been fully read.
+func (p *connPool) put(key string, conn net.Conn) {
+	conn.SetDeadline(time.Time{})
+	p.mu.Lock()
+	p.idle[key] = append(p.idle[key], conn)
+	p.mu.Unlock()
+}
+
+// closeAll closes every idle connection.
+func (p *connPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, conns := range p.idle {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(p.idle, key)
+	}
+}
+
+// keepAlive reports whether the connection that carried req and its
+// response raw can be used for another request: both sides spoke HTTP/1.1,
+// neither asked to close it, the request body, if any, was sent and the end
+// of the response was delimited by its framing rather than by the server
+// closing the connection.
+func keepAlive(req *request, raw []byte, resp *Response) bool {
+	if req.proto != "HTTP/1.1" || resp.Proto != "HTTP/1.1" || req.bodySkipped {
+		return false
+	}
+	for _, h := range req.headers {
+		if strings.EqualFold(h.name, "Connection") && hasToken(h.value, "close") {
+			return false
+		}
+	}
+	for _, v := range resp.Headers["Connection"] {
+		if hasToken(v, "close") {
+			return false
+		}
+	}
+	return responseComplete(raw, req.method == "HEAD", false)
+}
+
+// hasToken reports whether the comma-separated header value v lists token,
+// compared case-insensitively.
+func hasToken(v, token string) bool {
+	for _, t := range strings.Split(v, ",") {
+		if strings.EqualFold(strings.TrimSpace(t), token) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,31 @@
+package curl
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime"
+	"strings"
+)
+
+// isJSONType reports whether contentType, a Content-Type header value, names
+// JSON: application/json or a type with a +json suffix.
+func isJSONType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// prettyJSON re-indents the JSON document body for --pretty. ok is false if
+// body is not valid JSON.
+func prettyJSON(body []byte) (pretty []byte, ok bool) {
+	var b bytes.Buffer
+	if err := json.Indent(&b, body, "", "  "); err != nil {
+		return nil, false
+	}
+	if !bytes.HasSuffix(b.Bytes(), []byte("\n")) {
+		b.WriteByte('\n')
+	}
+	return b.Bytes(), true
+}
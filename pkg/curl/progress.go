@@ -0,0 +1,190 @@
+package curl
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	progressInterval = 500 * time.Millisecond // how often the meter is redrawn
+	progressWidth    = 64                     // width the meter line is padded to
+)
+
+// progressMeter draws a download progress line on stderr while a response
+// is read: percentage, bytes received, average speed and time left when the
+// size is known from Content-Length, and just bytes and speed otherwise.
+type progressMeter struct {
+	w        io.Writer // stderr, or the --stderr file
+	mu       sync.Mutex
+	start    time.Time
+	total    int64 // expected body size, or -1 when unknown
+	received int64 // body bytes read so far
+	header   bool  // whether the header block has been seen
+
+	// ignoreLength is set by --ignore-content-length, which leaves the
+	// size unknown.
+	ignoreLength bool
+
+	uploadTotal int64 // size of the request body being uploaded, or 0
+	sent        int64 // upload bytes sent so far
+
+	batch *batchProgress // draws this meter as part of a -Z batch, if set
+	done  chan struct{}
+	wg    sync.WaitGroup
+}
+
+// startProgress starts a meter for one response, or returns nil when the
+// transfer shows none. All meter methods accept a nil receiver.
+func (t *transfer) startProgress() *progressMeter {
+	if t.batch != nil {
+		p := &progressMeter{start: time.Now(), total: -1, ignoreLength: t.IgnoreContentLength, batch: t.batch}
+		t.batch.add(p)
+		return p
+	}
+	if !t.progress {
+		return nil
+	}
+
+	p := &progressMeter{w: t.stderr(), start: time.Now(), total: -1, ignoreLength: t.IgnoreContentLength, done: make(chan struct{})}
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		ticker := time.NewTicker(progressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				p.draw()
+			case <-p.done:
+				return
+			}
+		}
+	}()
+	return p
+}
+
+// uploading returns a reader that reports the upload of size bytes read
+// from r to the meter.
+func (p *progressMeter) uploading(r io.Reader, size int64) io.Reader {
+	if p == nil {
+		return r
+	}
+	p.mu.Lock()
+	p.uploadTotal = size
+	p.mu.Unlock()
+	return &uploadReader{r: r, p: p}
+}
+
+// uploadReader counts the bytes read through it as sent.
+type uploadReader struct {
+	r io.Reader
+	p *progressMeter
+}
+
+func (u *uploadReader) Read(b []byte) (int, error) {
+	n, err := u.r.Read(b)
+	u.p.mu.Lock()
+	u.p.sent += int64(n)
+	u.p.mu.Unlock()
+	return n, err
+}
+
+// update records the raw response read so far.
+func (p *progressMeter) update(raw []byte) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	head, body := splitResponse(raw)
+	if body == nil {
+		return
+	}
+	if !p.header {
+		p.header = true
+		if cl, ok := headerValue(head, "Content-Length"); ok && !p.ignoreLength {
+			if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+				p.total = n
+			}
+		}
+	}
+	p.received = int64(len(body))
+}
+
+// stop halts the meter and draws its final state.
+func (p *progressMeter) stop() {
+	if p == nil {
+		return
+	}
+	if p.batch != nil {
+		p.batch.remove(p)
+		return
+	}
+	close(p.done)
+	p.wg.Wait()
+	p.draw()
+	fmt.Fprintln(p.w)
+}
+
+func (p *progressMeter) draw() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	elapsed := time.Since(p.start)
+	speed := int64(0)
+	if elapsed > 0 {
+		speed = int64(float64(p.received) / elapsed.Seconds())
+	}
+
+	var line string
+	if p.uploadTotal > 0 && !p.header {
+		sendSpeed := int64(0)
+		if elapsed > 0 {
+			sendSpeed = int64(float64(p.sent) / elapsed.Seconds())
+		}
+		line = fmt.Sprintf("%5.1f%%  %8s / %-8s  %8s/s  uploaded",
+			float64(p.sent)*100/float64(p.uploadTotal), formatBytes(p.sent), formatBytes(p.uploadTotal), formatBytes(sendSpeed))
+	} else if p.total < 0 {
+		line = fmt.Sprintf("%8s received  %8s/s  %s elapsed",
+			formatBytes(p.received), formatBytes(speed), formatDuration(elapsed))
+	} else {
+		percent := 100.0
+		if p.total > 0 {
+			percent = float64(p.received) * 100 / float64(p.total)
+		}
+		left := "--:--:--"
+		if speed > 0 {
+			left = formatDuration(time.Duration(float64(p.total-p.received) / float64(speed) * float64(time.Second)))
+		}
+		line = fmt.Sprintf("%5.1f%%  %8s / %-8s  %8s/s  ETA %s",
+			percent, formatBytes(p.received), formatBytes(p.total), formatBytes(speed), left)
+	}
+	// Pad so that a shorter line fully covers the one drawn before it.
+	fmt.Fprintf(p.w, "\r%-*s", progressWidth, line)
+}
+
+// formatBytes renders n bytes with a binary unit suffix, like curl's meter.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10)
+	}
+	value, suffix := float64(n), ""
+	for _, s := range []string{"k", "M", "G", "T"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, s
+	}
+	return fmt.Sprintf("%.1f%s", value, suffix)
+}
+
+// formatDuration renders d as hh:mm:ss.
+func formatDuration(d time.Duration) string {
+	s := int64(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d:%02d", s/3600, s/60%60, s%60)
+}
@@ -0,0 +1,111 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/url"
+)
+
+// defaultProxyPort is the port used for a -x proxy that does not name one.
+const defaultProxyPort = "1080"
+
+// parseProxy parses the -x argument. A bare "host:port" is taken to be an
+// http proxy.
+func parseProxy(s string) (*url.URL, error) {
+	if schemeLen(s) == 0 {
+		s = "http://" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Hostname() == "" {
+		return nil, exitErrorf(exitURLMalformat, "invalid --proxy %q", s)
+	}
+	if u.Scheme != "http" {
+		return nil, exitErrorf(exitGeneric, "Unsupported proxy scheme %q", u.Scheme)
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultProxyPort)
+	}
+	return u, nil
+}
+
+// proxyAuth returns the Proxy-Authorization header value for the
+// credentials in the proxy URL, or "" if it has none.
+func proxyAuth(proxy *url.URL) string {
+	if proxy.User == nil {
+		return ""
+	}
+	password, _ := proxy.User.Password()
+	return basicAuth(proxy.User.Username() + ":" + password)
+}
+
+// tunnel asks the proxy on conn to open a tunnel to the host named by u,
+// through which the TLS connection to that host is then made.
+func (t *transfer) tunnel(conn net.Conn, u *url.URL) error {
+	port := u.Port()
+	if port == "" {
+		port = defaultPort(u.Scheme)
+	}
+	hostport := net.JoinHostPort(u.Hostname(), port)
+
+	req := &request{method: "CONNECT", target: hostport, proto: "HTTP/1.1"}
+	req.setHeader("Host", hostport)
+	if t.proxy != nil {
+		if auth := proxyAuth(t.proxy); auth != "" {
+			req.setHeader("Proxy-Authorization", auth)
+		}
+	}
+	if t.UserAgent != "" {
+		req.setHeader("User-Agent", t.UserAgent)
+	}
+	if t.Verbose {
+		t.infof("Establish HTTP proxy tunnel to %s", hostport)
+		t.dumpLines("> ", req.head())
+	}
+	if err := req.write(conn); err != nil {
+		return err
+	}
+
+	head, err := readTunnelResponse(conn)
+	if err != nil {
+		return err
+	}
+	resp, err := parseResponse(head)
+	if err != nil {
+		return err
+	}
+	if t.Verbose {
+		t.dumpLines("< ", resp.rawHeader)
+	}
+	if resp.StatusCode/100 != 2 {
+		return exitErrorf(exitRecv, "CONNECT tunnel failed, response %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// readTunnelResponse reads the proxy's reply to CONNECT up to the end of its
+// headers. It reads a byte at a time so that nothing sent after the headers
+// is consumed.
+func readTunnelResponse(conn net.Conn) ([]byte, error) {
+	var head []byte
+	b := make([]byte, 1)
+	for !bytes.HasSuffix(head, []byte("\r\n\r\n")) {
+		if _, err := conn.Read(b); err != nil {
+			return nil, exitErrorf(exitRecv, "Proxy CONNECT aborted: %w", err)
+		}
+		head = append(head, b[0])
+		if len(head) > maxTunnelHeader {
+			return nil, exitErrorf(exitWeirdReply, "Weird server reply: CONNECT response headers too large")
+		}
+	}
+	return head, nil
+}
+
+// maxTunnelHeader bounds the size of the proxy's reply to CONNECT.
+const maxTunnelHeader = 64 << 10
+
+// proxyTarget returns the absolute-form request target sent to a proxy for
+// a plain http request.
+func proxyTarget(u *url.URL, target string) string {
+	return fmt.Sprintf("%s://%s%s", u.Scheme, u.Host, target)
+}
@@ -0,0 +1,69 @@
+package curl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rateLimitedReader caps the average rate at which r is read, waiting
+// after each read as long as needed to stay under limit bytes per second.
+// A read takes no more than the limit allows in a tenth of a second, so
+// that each wait is short, and a wait ends early when ctx is done.
+type rateLimitedReader struct {
+	ctx   context.Context
+	r     io.Reader
+	limit int64
+	start time.Time
+	read  int64
+}
+
+func newRateLimitedReader(ctx context.Context, r io.Reader, limit int64) *rateLimitedReader {
+	return &rateLimitedReader{ctx: ctx, r: r, limit: limit, start: time.Now()}
+}
+
+func (l *rateLimitedReader) Read(p []byte) (int, error) {
+	if chunk := max(l.limit/10, 1); int64(len(p)) > chunk {
+		p = p[:chunk]
+	}
+	n, err := l.r.Read(p)
+	l.read += int64(n)
+
+	due := time.Duration(float64(l.read) / float64(l.limit) * float64(time.Second))
+	if wait := due - time.Since(l.start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-l.ctx.Done():
+			return 0, l.ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return n, err
+}
+
+// parseSize parses a byte count such as "200k" or "1M", where the suffixes
+// k, m and g (in either case) are powers of 1024.
+func parseSize(s string) (int64, error) {
+	num, mult := s, int64(1)
+	switch strings.ToLower(s[len(s)-min(len(s), 1):]) {
+	case "k":
+		mult = 1 << 10
+	case "m":
+		mult = 1 << 20
+	case "g":
+		mult = 1 << 30
+	}
+	if mult > 1 {
+		num = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
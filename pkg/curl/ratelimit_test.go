@@ -0,0 +1,36 @@
+package curl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRateLimitedReaderStopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	r := newRateLimitedReader(ctx, bytes.NewReader(make([]byte, 10000)), 100)
+
+	start := time.Now()
+	_, err := io.ReadAll(r)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("err = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("reading took %s after the deadline of 200ms", elapsed)
+	}
+}
+
+func TestRateLimitedReaderCapsReads(t *testing.T) {
+	r := newRateLimitedReader(context.Background(), bytes.NewReader(make([]byte, 10000)), 1000)
+	n, err := r.Read(make([]byte, 4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n > 100 {
+		t.Errorf("read %d bytes at once, want no more than the 100 allowed in a tenth of a second", n)
+	}
+}
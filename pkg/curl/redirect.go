@@ -0,0 +1,30 @@
+package curl
+
+import "net/http"
+
+// isRedirect reports whether code is a redirect status that -L follows.
+func isRedirect(code int) bool {
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectMethod returns the method and body to use when following a
+// redirect with status code. Like curl, 301, 302 and 303 turn anything but
+// GET and HEAD into a bodiless GET, unless keep is set, while 307 and 308
+// keep both unchanged.
+func redirectMethod(code int, method string, body []byte, keep bool) (string, []byte) {
+	if keep {
+		return method, body
+	}
+	switch code {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther:
+		if method != "GET" && method != "HEAD" {
+			return "GET", nil
+		}
+	}
+	return method, body
+}
@@ -0,0 +1,286 @@
+package curl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultUserAgent is sent as the User-Agent unless -A says otherwise.
+const defaultUserAgent = "build-your-own-curl/0.1"
+
+// header is a single request header field.
+type header struct {
+	name  string
+	value string
+}
+
+// request is an HTTP request ready to be written on the wire. Headers keep
+// the order in which they were added.
+type request struct {
+	method  string
+	target  string
+	proto   string
+	headers []header
+	body    []byte
+	upload  io.Reader // streamed after body, for -T
+	chunked bool      // whether upload is sent in chunked encoding
+
+	// answersDigest is set when a 401 response is answered with Digest
+	// credentials rather than being the final one.
+	answersDigest bool
+
+	// bodySkipped is set when the server answered an Expect: 100-continue
+	// request with a final status, so the body was never sent.
+	bodySkipped bool
+}
+
+// hop is one request of a transfer. Following a redirect moves on to a new
+// hop that may differ in more than just its URL.
+type hop struct {
+	method  string
+	url     *url.URL
+	rawPath string // path to send as it is, instead of the one in url
+	body    []byte
+	referer string
+	upload  bool // whether the -T file is sent as the body
+
+	// authorization answers a Digest challenge from an earlier response.
+	authorization string
+
+	// crossHost is set once a redirect has left the host first asked for.
+	// Credentials and cookies given on the command line are then withheld.
+	crossHost bool
+}
+
+// newRequest builds the request for hop h of transfer t. Headers derived
+// from the URL and body come first, so that -H can override any of them.
+func newRequest(t *transfer, h *hop) (*request, error) {
+	target := requestTarget(h.url)
+	if h.rawPath != "" {
+		target = withQuery(h.rawPath, h.url)
+	}
+	req := &request{method: h.method, target: target, proto: t.proto(), body: h.body, answersDigest: t.answersDigest(h)}
+	req.setHeader("Host", hostHeader(h.url))
+	if t.proxy != nil && h.url.Scheme == "http" {
+		req.target = proxyTarget(h.url, req.target)
+		if auth := proxyAuth(t.proxy); auth != "" {
+			req.setHeader("Proxy-Authorization", auth)
+		}
+	}
+	if t.RequestTarget != "" {
+		req.target = t.RequestTarget
+	}
+	if t.UserAgent != "" {
+		req.setHeader("User-Agent", t.UserAgent)
+	}
+	if h.referer != "" {
+		req.setHeader("Referer", h.referer)
+	}
+	if req.proto == "HTTP/1.1" && (t.pool == nil || t.IgnoreContentLength) {
+		// The connection is not going to be reused, so ask the server not
+		// to keep it alive. Without a Content-Length to go by, the end of
+		// the connection is also the only way to tell where the body ends.
+		req.setHeader("Connection", "close")
+	}
+	if t.Compressed {
+		req.setHeader("Accept-Encoding", acceptEncoding)
+	}
+	if t.isJSON() {
+		req.setHeader("Accept", "application/json")
+	}
+	switch {
+	case h.authorization != "":
+		req.setHeader("Authorization", h.authorization)
+	case t.Bearer != "" && !h.crossHost:
+		req.setHeader("Authorization", "Bearer "+t.Bearer)
+	case t.User != "" && !h.crossHost:
+		if !t.Digest {
+			req.setHeader("Authorization", basicAuth(t.User))
+		}
+	case t.netrc != nil:
+		// Unlike -u, netrc credentials are looked up for each host.
+		if userinfo, ok := t.netrc.credentials(h.url.Hostname()); ok {
+			req.setHeader("Authorization", basicAuth(userinfo))
+		}
+	}
+	if cookies := requestCookies(t, h); cookies != "" {
+		req.setHeader("Cookie", cookies)
+	}
+	if t.ifNoneMatch != "" {
+		req.setHeader("If-None-Match", t.ifNoneMatch)
+	}
+	if t.timeCond.name != "" {
+		req.setHeader(t.timeCond.name, t.timeCond.value)
+	}
+	if t.resumeFrom > 0 {
+		req.setHeader("Range", fmt.Sprintf("bytes=%d-", t.resumeFrom))
+	} else if t.ByteRange != "" {
+		req.setHeader("Range", "bytes="+t.ByteRange)
+	}
+	if req.body != nil {
+		req.setHeader("Content-Type", t.contentType)
+		req.setHeader("Content-Length", strconv.Itoa(len(req.body)))
+	}
+	if h.upload && t.uploadType != "" {
+		req.setHeader("Content-Type", t.uploadType)
+	}
+	if h.upload && t.uploadChunked {
+		req.setHeader("Transfer-Encoding", "chunked")
+		req.chunked = true
+	} else if h.upload {
+		req.setHeader("Content-Length", strconv.FormatInt(t.uploadSize, 10))
+	}
+	if req.proto == "HTTP/1.1" && (len(req.body) > expectThreshold || (h.upload && t.uploadSize > expectThreshold)) {
+		req.setHeader("Expect", "100-continue")
+	}
+
+	for _, raw := range t.Headers {
+		f, err := parseHeader(raw)
+		if err != nil {
+			return nil, err
+		}
+		if h.crossHost && (strings.EqualFold(f.name, "Authorization") || strings.EqualFold(f.name, "Cookie")) {
+			continue
+		}
+		req.setHeader(f.name, f.value)
+	}
+	return req, nil
+}
+
+// requestCookies returns the Cookie header value for h: a literal -b cookie
+// string, while still on the original host, followed by the cookies in the
+// jar that match the URL.
+func requestCookies(t *transfer, h *hop) string {
+	var parts []string
+	if strings.Contains(t.Cookie, "=") && !h.crossHost {
+		parts = append(parts, t.Cookie)
+	}
+	if c := t.jar.header(h.url); c != "" {
+		parts = append(parts, c)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// setHeader sets the header called name to value, replacing the first
+// existing header with that name (compared case-insensitively) or appending
+// a new one.
+func (r *request) setHeader(name, value string) {
+	for i, h := range r.headers {
+		if strings.EqualFold(h.name, name) {
+			r.headers[i] = header{name: name, value: value}
+			return
+		}
+	}
+	r.headers = append(r.headers, header{name: name, value: value})
+}
+
+// head returns the request line and headers, including the blank line that
+// terminates them.
+func (r *request) head() []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s %s %s\r\n", r.method, r.target, r.proto)
+	for _, h := range r.headers {
+		fmt.Fprintf(&b, "%s: %s\r\n", h.name, h.value)
+	}
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
+
+// write sends the request line, headers and body to w.
+func (r *request) write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.Write(r.head())
+	if err := r.writeBody(bw); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// writeBody sends just the body to w.
+func (r *request) writeBody(w io.Writer) error {
+	if _, err := w.Write(r.body); err != nil {
+		return err
+	}
+	if r.upload == nil {
+		return nil
+	}
+	if !r.chunked {
+		_, err := io.Copy(w, r.upload)
+		return err
+	}
+	cw := &chunkedWriter{w: w}
+	if _, err := io.Copy(cw, r.upload); err != nil {
+		return err
+	}
+	return cw.close()
+}
+
+// parseHeader parses a "Name: Value" string as given to -H.
+func parseHeader(s string) (header, error) {
+	name, value, ok := strings.Cut(s, ":")
+	name = strings.TrimSpace(name)
+	if !ok || name == "" {
+		return header{}, fmt.Errorf("invalid header %q: expected \"Name: Value\"", s)
+	}
+	return header{name: name, value: strings.TrimSpace(value)}, nil
+}
+
+// hostHeader returns the Host header for a request to u: its host, with the
+// port unless it is the default one for the scheme. An IPv6 literal keeps
+// its brackets.
+func hostHeader(u *url.URL) string {
+	if port := u.Port(); port != "" && port != defaultPort(u.Scheme) {
+		return u.Host
+	}
+	host := u.Hostname()
+	if strings.Contains(host, ":") {
+		return "[" + host + "]"
+	}
+	return host
+}
+
+// validTarget reports whether target, given to --request-target, can go on
+// the request line: a single run of visible characters, with no spaces.
+func validTarget(target string) bool {
+	if target == "" {
+		return false
+	}
+	for _, r := range target {
+		if r <= ' ' || r >= 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+// validRange reports whether spec is a valid -r range list: comma-separated
+// "first-last", "first-" or "-suffix" byte ranges.
+func validRange(spec string) bool {
+	for _, r := range strings.Split(spec, ",") {
+		first, last, ok := strings.Cut(strings.TrimSpace(r), "-")
+		if !ok || (first == "" && last == "") {
+			return false
+		}
+		var from, to uint64
+		var err error
+		if first != "" {
+			if from, err = strconv.ParseUint(first, 10, 64); err != nil {
+				return false
+			}
+		}
+		if last != "" {
+			if to, err = strconv.ParseUint(last, 10, 64); err != nil {
+				return false
+			}
+		}
+		if first != "" && last != "" && to < from {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,108 @@
+package curl
+
+import (
+	"fmt"
+	"strings"
+)
+
+// resolveEntry is a --resolve mapping of a host and port to a fixed address.
+type resolveEntry struct {
+	host string
+	port string
+	addr string
+}
+
+// connectToEntry is a --connect-to mapping of a host and port to another
+// endpoint. Empty fields match any host or port, or keep the original one.
+type connectToEntry struct {
+	host        string
+	port        string
+	connectHost string
+	connectPort string
+}
+
+// parseResolve parses a HOST:PORT:ADDRESS argument to --resolve. ADDRESS may
+// be an IPv6 address in brackets.
+func parseResolve(s string) (resolveEntry, error) {
+	fields := splitFields(s, 3)
+	if fields == nil || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+		return resolveEntry{}, fmt.Errorf("invalid --resolve %q: expected HOST:PORT:ADDRESS", s)
+	}
+	return resolveEntry{host: fields[0], port: fields[1], addr: unbracket(fields[2])}, nil
+}
+
+// parseConnectTo parses a HOST:PORT:CONNECT_HOST:CONNECT_PORT argument to
+// --connect-to.
+func parseConnectTo(s string) (connectToEntry, error) {
+	fields := splitFields(s, 4)
+	if fields == nil {
+		return connectToEntry{}, fmt.Errorf("invalid --connect-to %q: expected HOST:PORT:CONNECT_HOST:CONNECT_PORT", s)
+	}
+	return connectToEntry{
+		host:        unbracket(fields[0]),
+		port:        fields[1],
+		connectHost: unbracket(fields[2]),
+		connectPort: fields[3],
+	}, nil
+}
+
+// connectTarget returns the host and port to connect to for host and port,
+// applying the first matching --connect-to entry.
+func (t *transfer) connectTarget(host, port string) (string, string) {
+	for _, e := range t.connectTo {
+		if (e.host == "" || strings.EqualFold(e.host, host)) && (e.port == "" || e.port == port) {
+			if e.connectHost != "" {
+				host = e.connectHost
+			}
+			if e.connectPort != "" {
+				port = e.connectPort
+			}
+			return host, port
+		}
+	}
+	return host, port
+}
+
+// resolveAddr returns the address --resolve gives for host and port, if any.
+func (t *transfer) resolveAddr(host, port string) (string, bool) {
+	for _, e := range t.resolve {
+		if strings.EqualFold(e.host, host) && e.port == port {
+			return e.addr, true
+		}
+	}
+	return "", false
+}
+
+// splitFields splits s into exactly n colon-separated fields, ignoring colons
+// inside square brackets so that IPv6 addresses can be given. It returns nil
+// if s does not have n fields.
+func splitFields(s string, n int) []string {
+	var fields []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				fields = append(fields, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	fields = append(fields, s[start:])
+	if len(fields) != n {
+		return nil
+	}
+	return fields
+}
+
+// unbracket strips the square brackets around an IPv6 address.
+func unbracket(host string) string {
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		return host[1 : len(host)-1]
+	}
+	return host
+}
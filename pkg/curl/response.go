@@ -0,0 +1,341 @@
+package curl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Response is a parsed HTTP response.
+type Response struct {
+	Proto      string // e.g. "HTTP/1.1"
+	StatusCode int    // e.g. 200
+	Status     string // e.g. "200 OK"
+	Headers    map[string][]string
+	Body       []byte
+
+	rawHeader  []byte   // status line and headers as received, up to the blank line
+	rawInterim []byte   // header blocks of the 1xx responses that came first
+	rawTrailer []byte   // trailer fields sent after a chunked body
+	url        *url.URL // the URL requested
+	timing     hopTiming
+
+	// wireSize is the size of the body as it came over the connection,
+	// framing and codings included, and decodedSize the size written out
+	// once they were undone, or -1 when it was not written.
+	wireSize    int64
+	decodedSize int64
+}
+
+// header returns the first value of the header called name, or "".
+func (r *Response) header(name string) string {
+	if v := r.Headers[textproto.CanonicalMIMEHeaderKey(name)]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// parseResponse parses the raw bytes of a response into a Response, which
+// describes the final response when interim 1xx ones came first. Header
+// names are canonicalized and repeated headers keep all of their values. The
+// body is stripped of chunked framing or cut to its Content-Length, but any
+// other Transfer-Encoding is left to the caller to undo.
+func parseResponse(raw []byte) (*Response, error) {
+	return parse(raw, false)
+}
+
+// parsePartialResponse is parseResponse for a response that was not read to
+// its end, for --head-bytes: a chunked body is kept up to where it stops.
+func parsePartialResponse(raw []byte) (*Response, error) {
+	return parse(raw, true)
+}
+
+func parse(raw []byte, partial bool) (*Response, error) {
+	interim, raw := splitInterim(raw)
+	head, body := splitHead(raw)
+	lines := strings.Split(strings.TrimSuffix(string(head), "\r\n\r\n"), "\r\n")
+
+	proto, status, _ := strings.Cut(lines[0], " ")
+	code, _, _ := strings.Cut(status, " ")
+	statusCode, err := strconv.Atoi(code)
+	if !strings.HasPrefix(proto, "HTTP/") || len(code) != 3 || err != nil {
+		return nil, exitErrorf(exitWeirdReply, "Weird server reply: malformed status line %q", lines[0])
+	}
+
+	resp := &Response{
+		Proto:       proto,
+		StatusCode:  statusCode,
+		Status:      strings.TrimSpace(status),
+		Headers:     make(map[string][]string),
+		rawHeader:   head,
+		rawInterim:  interim,
+		wireSize:    int64(len(body)),
+		decodedSize: -1,
+	}
+	for _, line := range lines[1:] {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, exitErrorf(exitWeirdReply, "Weird server reply: malformed header line %q", line)
+		}
+		key := textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))
+		resp.Headers[key] = append(resp.Headers[key], strings.TrimSpace(value))
+	}
+
+	te := strings.Join(resp.Headers["Transfer-Encoding"], ", ")
+	switch {
+	case hasChunked(te):
+		if body, resp.rawTrailer, err = decodeChunked(body); err != nil && !(partial && errors.Is(err, io.ErrUnexpectedEOF)) {
+			return nil, exitErrorf(exitRecv, "Problem with the chunked encoding: %w", err)
+		}
+	case te != "":
+		// Delimited by the end of the connection.
+	case resp.header("Content-Length") != "":
+		length, err := strconv.Atoi(resp.header("Content-Length"))
+		if err != nil || length < 0 {
+			return nil, exitErrorf(exitWeirdReply, "Weird server reply: invalid Content-Length %q", resp.header("Content-Length"))
+		}
+		if length < len(body) {
+			body = body[:length]
+		}
+	}
+	resp.Body = body
+	return resp, nil
+}
+
+// readResponse reads the raw response from r, a connection, until the server
+// closes it or, when a Content-Length header is present, until that many
+// body bytes have been consumed. Responses to HEAD requests carry no body, so
+// reading stops after the header block. A body known or found to be larger
+// than --max-filesize fails with errFileSize as soon as that is clear, and a
+// header block that runs past --max-headers-size with errHeaderSize.
+// Progress is reported to meter, and with -N what is read is handed to the
+// stream to write out.
+func (t *transfer) readResponse(r io.Reader, method string, meter *progressMeter) ([]byte, error) {
+	var raw []byte
+	var body bodyTracker
+	headDone := false
+	buf := make([]byte, 1024)
+	for {
+		n, err := r.Read(buf)
+		raw = append(raw, buf[:n]...)
+		if !headDone && t.maxHeadersSize > 0 && int64(len(raw)) > t.maxHeadersSize {
+			// The read may have brought in the end of the header block
+			// along with body bytes; only the block itself counts.
+			end := headEnd(raw)
+			if end == 0 || int64(end) > t.maxHeadersSize {
+				return nil, errHeaderSize
+			}
+			headDone = true
+		}
+		meter.update(raw)
+		body.update(raw)
+		complete, serr := t.stream.feed(raw)
+		if serr != nil {
+			return nil, serr
+		}
+		if t.maxFilesize > 0 && method != "HEAD" && body.size(t.IgnoreContentLength) > t.maxFilesize {
+			return nil, errFileSize
+		}
+		if err == io.EOF {
+			return raw, nil
+		}
+		if err != nil {
+			return raw, err
+		}
+		if !t.stream.streaming() {
+			complete = body.complete(method == "HEAD", t.IgnoreContentLength)
+		}
+		if complete {
+			return raw, nil
+		}
+	}
+}
+
+// errFileSize reports a response body over the --max-filesize limit.
+var errFileSize = exitErrorf(exitFileSize, "Maximum file size exceeded")
+
+// errHeaderSize reports a response header block over the
+// --max-headers-size limit.
+var errHeaderSize = exitErrorf(exitTooLarge, "Header block too large")
+
+// headEnd returns the offset in raw at which the header block of the final
+// response ends, those of any 1xx responses before it included, or 0 when
+// raw does not hold all of it yet.
+func headEnd(raw []byte) int {
+	_, body := splitResponse(raw)
+	if body == nil {
+		return 0
+	}
+	return len(raw) - len(body)
+}
+
+// bodyTracker follows the body of a response as readResponse reads it in,
+// parsing only what is new each time, so that its size and whether it is
+// complete are known without going over the body read before.
+type bodyTracker struct {
+	head   []byte       // header block of the final response, once it is in
+	start  int          // offset of the body in raw, or 0 until then
+	end    int          // length of raw at the last update
+	chunks *chunkParser // framing of a chunked body, or nil
+	data   int64        // chunk data read so far
+	done   bool         // whether the last chunk and its trailer are in
+	err    error        // malformed chunked framing, if found
+}
+
+// update records the raw response read so far.
+func (b *bodyTracker) update(raw []byte) {
+	if b.start == 0 {
+		head, body := splitResponse(raw)
+		if body == nil {
+			return
+		}
+		b.head, b.start, b.end = head, len(raw)-len(body), len(raw)-len(body)
+		if isChunked(head) {
+			b.chunks = &chunkParser{}
+		}
+	}
+	if b.chunks != nil && !b.done && b.err == nil {
+		b.done, b.err = b.chunks.feed(raw[b.end:], func(p []byte) error {
+			b.data += int64(len(p))
+			return nil
+		})
+	}
+	b.end = len(raw)
+}
+
+// size returns the size of the body: the Content-Length once the header
+// block is in, unless ignoreLength is set, or else the body bytes read so
+// far, without any chunked framing.
+func (b *bodyTracker) size(ignoreLength bool) int64 {
+	if b.start == 0 {
+		return 0
+	}
+	if cl, ok := headerValue(b.head, "Content-Length"); ok && !ignoreLength {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+			return n
+		}
+	}
+	if b.chunks != nil {
+		return b.data
+	}
+	return int64(b.end - b.start)
+}
+
+// complete reports what responseComplete does of the raw response read so
+// far.
+func (b *bodyTracker) complete(headOnly, ignoreLength bool) bool {
+	if b.start == 0 {
+		return false
+	}
+	if headOnly || bodilessStatus(b.head) {
+		return true
+	}
+	if b.chunks != nil {
+		// Malformed framing counts as complete so the error surfaces when
+		// the body is decoded.
+		return b.done || b.err != nil
+	}
+	if cl, ok := headerValue(b.head, "Content-Length"); ok && !ignoreLength {
+		length, err := strconv.Atoi(cl)
+		return err == nil && b.end-b.start >= length
+	}
+	return false
+}
+
+// splitResponse splits raw after the blank line that ends the header block
+// of the final response, returning its status line and headers separately
+// from the body. Interim 1xx responses ahead of it are skipped. A response
+// without a complete header block is treated as all header.
+func splitResponse(raw []byte) (head, body []byte) {
+	_, raw = splitInterim(raw)
+	return splitHead(raw)
+}
+
+// splitHead splits raw after the blank line that ends its first header
+// block.
+func splitHead(raw []byte) (head, body []byte) {
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	if end < 0 {
+		return raw, nil
+	}
+	return raw[:end+4], raw[end+4:]
+}
+
+// splitInterim splits the header blocks of the interim 1xx responses, such
+// as 100 Continue or 103 Early Hints, off the start of raw, returning them
+// apart from the rest. 101 Switching Protocols is final, as nothing follows
+// it in HTTP/1.1.
+func splitInterim(raw []byte) (interim, rest []byte) {
+	for {
+		head, body := splitHead(raw)
+		code := statusCode(head)
+		if body == nil || len(code) != 3 || code[0] != '1' || string(code) == "101" {
+			return interim, raw
+		}
+		interim = append(interim, head...)
+		raw = body
+	}
+}
+
+// responseComplete reports whether raw holds the full header block and a
+// body delimited by its chunked framing or Content-Length, unless
+// ignoreLength says to pay the latter no mind. Responses with neither are
+// only complete once the connection is closed, unless headOnly says no body
+// is expected or the status code rules one out.
+func responseComplete(raw []byte, headOnly, ignoreLength bool) bool {
+	head, body := splitResponse(raw)
+	if body == nil && !bytes.HasSuffix(head, []byte("\r\n\r\n")) {
+		return false
+	}
+	if headOnly || bodilessStatus(head) {
+		return true
+	}
+
+	if isChunked(head) {
+		return chunkedComplete(body)
+	}
+	if cl, ok := headerValue(head, "Content-Length"); ok && !ignoreLength {
+		length, err := strconv.Atoi(cl)
+		if err != nil {
+			return false
+		}
+		return len(body) >= length
+	}
+	return false
+}
+
+// bodilessStatus reports whether the status line of the raw header block
+// head has a 1xx, 204 or 304 code, which never come with a body.
+func bodilessStatus(head []byte) bool {
+	line, _, _ := bytes.Cut(head, []byte("\r\n"))
+	fields := strings.Fields(string(line))
+	if len(fields) < 2 {
+		return false
+	}
+	code := fields[1]
+	return strings.HasPrefix(code, "1") || code == "204" || code == "304"
+}
+
+// headerValue returns the value of the first header called name in the raw
+// header block head.
+func headerValue(head []byte, name string) (string, bool) {
+	for _, line := range strings.Split(string(head), "\r\n")[1:] {
+		n, value, ok := strings.Cut(line, ":")
+		if ok && strings.EqualFold(strings.TrimSpace(n), name) {
+			return strings.TrimSpace(value), true
+		}
+	}
+	return "", false
+}
+
+// chunkedComplete reports whether body holds a whole chunked message, up to
+// and including the zero-size last chunk and its trailer. Malformed framing
+// counts as complete so the error surfaces when the body is decoded.
+func chunkedComplete(body []byte) bool {
+	_, _, err := decodeChunked(body)
+	return !errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,82 @@
+package curl
+
+import (
+	"strings"
+	"testing"
+)
+
+// endlessHeaders is a response whose header block never ends.
+type endlessHeaders struct {
+	started bool
+	read    int64
+}
+
+func (r *endlessHeaders) Read(p []byte) (int, error) {
+	if !r.started {
+		r.started = true
+		n := copy(p, "HTTP/1.1 200 OK\r\n")
+		r.read += int64(n)
+		return n, nil
+	}
+	const line = "X-Filler: aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\r\n"
+	n := 0
+	for n+len(line) <= len(p) {
+		n += copy(p[n:], line)
+	}
+	r.read += int64(n)
+	return n, nil
+}
+
+func TestReadResponseHeaderTooLarge(t *testing.T) {
+	const limit = 100 * 1024
+	r := &endlessHeaders{}
+	tr := &transfer{Options: &Options{}, maxHeadersSize: limit}
+	_, err := tr.readResponse(r, "GET", nil)
+	if err == nil {
+		t.Fatal("readResponse of an endless header block succeeded")
+	}
+	if code := Classify(err).Code; code != exitTooLarge {
+		t.Errorf("exit code = %d, want %d", code, exitTooLarge)
+	}
+	if r.read > 2*limit {
+		t.Errorf("read %d bytes before giving up, want about %d", r.read, limit)
+	}
+}
+
+func TestReadResponseHeaderWithinLimit(t *testing.T) {
+	head := "HTTP/1.1 200 OK\r\nX-Big: " + strings.Repeat("a", 50*1024) + "\r\nContent-Length: 2\r\n\r\nok"
+	tr := &transfer{Options: &Options{}, maxHeadersSize: 100 * 1024}
+	raw, err := tr.readResponse(strings.NewReader(head), "GET", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := parseResponse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "ok" {
+		t.Errorf("body = %q, want %q", resp.Body, "ok")
+	}
+}
+
+func TestReadResponseHeaderEndsPastLimit(t *testing.T) {
+	const limit = 1000
+	// headerBlock returns a header block of exactly n bytes.
+	headerBlock := func(n int) string {
+		const start, end = "HTTP/1.1 200 OK\r\nX-Pad: ", "\r\nContent-Length: 20\r\n\r\n"
+		return start + strings.Repeat("a", n-len(start)-len(end)) + end
+	}
+	body := strings.Repeat("b", 20)
+
+	// The whole response comes in one read, so the block is only over
+	// the limit by where it ends.
+	tr := &transfer{Options: &Options{}, maxHeadersSize: limit}
+	_, err := tr.readResponse(strings.NewReader(headerBlock(limit+2)+body), "GET", nil)
+	if code := Classify(err).Code; err == nil || code != exitTooLarge {
+		t.Errorf("header block of %d bytes: err = %v, want exit code %d", limit+2, err, exitTooLarge)
+	}
+
+	if _, err := tr.readResponse(strings.NewReader(headerBlock(limit)+body), "GET", nil); err != nil {
+		t.Errorf("header block of exactly %d bytes: %v", limit, err)
+	}
+}
@@ -0,0 +1,118 @@
+package curl
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// maxRetryDelay caps the exponential backoff between --retry attempts.
+const maxRetryDelay = 10 * time.Minute
+
+// perform requests u, following redirects as follow does, and tries again up
+// to --retry times after a transient failure, or after any failure or HTTP
+// error with --retry-all-errors. --max-time bounds each attempt on its own,
+// while --retry-max-time bounds how long retries are started for.
+func (t *transfer) perform(ctx context.Context, method string, u *url.URL) ([]*Response, error) {
+	start := time.Now()
+	backoff := time.Second
+	for retriesLeft := t.Retry; ; retriesLeft-- {
+		hops, err := t.attempt(ctx, method, u)
+		if retriesLeft <= 0 || ctx.Err() != nil {
+			return hops, err
+		}
+
+		var reason string
+		delay := backoff
+		switch {
+		case err != nil:
+			if !transientError(err) && !t.RetryAllErrors {
+				return hops, err
+			}
+			reason = Classify(err).Error()
+		case t.retriesStatus(hops[len(hops)-1].StatusCode):
+			final := hops[len(hops)-1]
+			reason = "HTTP error " + strconv.Itoa(final.StatusCode)
+			if after, ok := retryAfter(final.header("Retry-After"), time.Now()); ok {
+				delay = after
+			}
+		default:
+			return hops, err
+		}
+		if t.RetryDelay > 0 {
+			delay = seconds(t.RetryDelay)
+		}
+		if t.RetryMaxTime > 0 && time.Since(start)+delay > seconds(t.RetryMaxTime) {
+			return hops, err
+		}
+
+		if t.Verbose {
+			t.warnf("Transient problem: %s. Will retry in %g seconds (%d of %d).", reason, delay.Seconds(), t.Retry-retriesLeft+1, t.Retry)
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		backoff = min(2*backoff, maxRetryDelay)
+	}
+}
+
+// attempt makes one try at the transfer for perform, given --max-time of
+// its own.
+func (t *transfer) attempt(ctx context.Context, method string, u *url.URL) ([]*Response, error) {
+	if t.MaxTime <= 0 {
+		return t.follow(ctx, method, u)
+	}
+	start := time.Now()
+	attemptCtx, cancel := context.WithTimeout(ctx, seconds(t.MaxTime))
+	defer cancel()
+	hops, err := t.follow(attemptCtx, method, u)
+	// The connection deadline can fire a moment before attemptCtx notes
+	// that it expired, so go by the clock too.
+	expired := attemptCtx.Err() == context.DeadlineExceeded || time.Since(start) >= seconds(t.MaxTime)
+	if err != nil && expired && ctx.Err() == nil {
+		err = exitErrorf(exitTimeout, "Operation timed out after %d ms", time.Since(start).Milliseconds())
+	}
+	return hops, err
+}
+
+// transientError reports whether err is a failure that might not happen
+// again: the connection being refused or reset, or a timeout.
+func transientError(err error) bool {
+	switch Classify(err).Code {
+	case exitConnect, exitTimeout, exitSend, exitRecv:
+		return true
+	}
+	return false
+}
+
+// transientStatus reports whether an HTTP status means the server may
+// succeed if asked again later.
+func transientStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code/100 == 5
+}
+
+// retriesStatus reports whether a final response with status code is
+// retried: one that is transient, or any HTTP error with
+// --retry-all-errors.
+func (t *transfer) retriesStatus(code int) bool {
+	return transientStatus(code) || (t.RetryAllErrors && code >= 400)
+}
+
+// retryAfter parses a Retry-After header value, given either in seconds or
+// as an HTTP date, into the time to wait from now.
+func retryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if when, err := http.ParseTime(value); err == nil {
+		return max(when.Sub(now), 0), true
+	}
+	return 0, false
+}
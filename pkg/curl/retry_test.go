@@ -0,0 +1,82 @@
+package curl
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPerformRetriesUntilSuccess(t *testing.T) {
+	var attempts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch attempts.Add(1) {
+		case 1:
+			// Outlast --max-time, which this attempt should time out on.
+			select {
+			case <-time.After(2 * time.Second):
+			case <-r.Context().Done():
+			}
+		case 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer srv.Close()
+
+	var stderr bytes.Buffer
+	o := &Options{
+		Verbose:      true,
+		Retry:        3,
+		RetryDelay:   0.01,
+		RetryMaxTime: 5,
+		MaxTime:      0.2,
+		errOut:       &stderr,
+	}
+	tr := &transfer{Options: o, jar: &cookieJar{}}
+	u, err := url.Parse(srv.URL + "/x")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	hops, err := tr.perform(context.Background(), "GET", u)
+	if err != nil {
+		t.Fatalf("perform: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("perform took %v, want the first attempt cut short by --max-time", elapsed)
+	}
+	if n := attempts.Load(); n != 3 {
+		t.Errorf("server saw %d attempts, want 3", n)
+	}
+	if final := hops[len(hops)-1]; final.StatusCode != http.StatusOK || string(final.Body) != "ok" {
+		t.Errorf("final response %d %q, want 200 \"ok\"", final.StatusCode, final.Body)
+	}
+
+	var warnings []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if strings.HasPrefix(line, "Warning: ") {
+			warnings = append(warnings, line)
+		}
+	}
+	want := []string{
+		"Warning: Transient problem: Operation timed out after ",
+		"Warning: Transient problem: HTTP error 503. Will retry in 0.01 seconds (2 of 3).",
+	}
+	if len(warnings) != len(want) {
+		t.Fatalf("warnings = %q, want %d of them", warnings, len(want))
+	}
+	if !strings.HasPrefix(warnings[0], want[0]) || !strings.HasSuffix(warnings[0], "Will retry in 0.01 seconds (1 of 3).") {
+		t.Errorf("first warning = %q", warnings[0])
+	}
+	if warnings[1] != want[1] {
+		t.Errorf("second warning = %q, want %q", warnings[1], want[1])
+	}
+}
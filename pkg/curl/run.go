@@ -0,0 +1,393 @@
+package curl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// progName is the name of the command, used to prefix error messages.
+const progName = "build-your-own-curl"
+
+// runAll fetches each of urls, after expanding their globs unless -g is
+// set, sharing one cookie jar and the connections of pool between them,
+// unless --no-keepalive turns reuse off. They are
+// fetched in turn, or several at a time with -Z. A failure is reported as it
+// happens and the remaining URLs are still fetched unless -f or
+// --fail-with-body is set; the exit status is that of the last failure.
+func runAll(ctx context.Context, o *Options, urls []string, pool *connPool) error {
+	jar, err := newCookieJar(o.Cookie)
+	if err != nil {
+		return err
+	}
+	s := &session{jar: jar}
+	if o.Netrc || o.NetrcFile != "" {
+		if s.netrc, err = loadNetrc(o); err != nil {
+			return err
+		}
+	}
+	if !o.NoKeepalive {
+		s.pool = pool
+	}
+	if o.DumpHeader != "" {
+		if s.headerDump, err = openHeaderDump(o.DumpHeader); err != nil {
+			return err
+		}
+		s.headerDump.final = o.DumpHeaderFinal
+		defer s.headerDump.close()
+	} else if o.DumpHeaderFinal {
+		o.warnf("--dump-header-final has no effect without -D")
+	}
+	if o.HeadersJSON != "" {
+		if s.headersJSON, err = openHeaderDump(o.HeadersJSON); err != nil {
+			return err
+		}
+		s.headersJSON.json = true
+		defer s.headersJSON.close()
+	}
+	if o.DoHURL != "" {
+		if s.doh, err = newDoHResolver(o.DoHURL); err != nil {
+			return err
+		}
+	}
+	if name, ascii := o.traceFile(); name != "" {
+		if s.tracer, err = openTracer(name, ascii, o.stderr()); err != nil {
+			return err
+		}
+		defer s.tracer.close()
+	}
+	if o.AppendOutput {
+		switch {
+		case len(o.Output) > 0 || o.RemoteName:
+		case o.Upload != "":
+			o.warnf("--append only applies to -o and -O; an HTTP upload with -T has nothing to append to")
+		default:
+			return errors.New("--append needs -o, -O or -T")
+		}
+	}
+	if o.IgnoreContentLength {
+		if o.HTTP2 {
+			o.warnf("--ignore-content-length has no effect on HTTP/2, where the end of a body is always marked")
+		}
+		for _, raw := range o.Headers {
+			if f, err := parseHeader(raw); err == nil && strings.EqualFold(f.name, "Connection") && !hasToken(f.value, "close") {
+				o.warnf("--ignore-content-length reads the body until the server closes the connection, which it may not do with \"Connection: %s\"", f.value)
+			}
+		}
+	}
+	if o.Bearer != "" && o.User != "" {
+		o.warnf("--oauth2-bearer takes precedence over -u; the -u credentials are not sent")
+	}
+
+	status := 0
+	var jobs []job
+	for i, arg := range urls {
+		expanded := []globURL{{url: arg}}
+		if !o.GlobOff {
+			if expanded, err = expandGlob(arg); err != nil {
+				status = o.Report(err)
+				continue
+			}
+		}
+		for _, g := range expanded {
+			jobs = append(jobs, job{url: g, index: i})
+		}
+	}
+
+	if o.Parallel {
+		if code := runParallel(ctx, o, s, jobs); code != 0 {
+			status = code
+		}
+	} else {
+		for _, j := range jobs {
+			if ctx.Err() != nil {
+				break
+			}
+			if err := run(ctx, o, s, j.url, j.index, nil); err != nil {
+				status = o.Report(err)
+				if o.failEarly() {
+					break
+				}
+			}
+		}
+	}
+
+	if o.CookieJar != "" {
+		if err := jar.save(o.CookieJar); err != nil {
+			return err
+		}
+	}
+	if status != 0 {
+		return &ExitError{Code: status}
+	}
+	return nil
+}
+
+// session is the state shared by all the transfers of one invocation.
+type session struct {
+	jar   *cookieJar
+	netrc netrc     // credentials from -n or --netrc-file, or nil
+	pool  *connPool // idle connections kept alive, or nil with --no-keepalive
+
+	headerDump  *headerDump  // where -D writes response headers, or nil
+	headersJSON *headerDump  // where --output-headers-json writes them, or nil
+	tracer      *tracer      // where --trace writes the traffic, or nil
+	doh         *dohResolver // resolver for --doh-url, or nil
+}
+
+// job is one URL to fetch, after glob expansion.
+type job struct {
+	url   globURL
+	index int // of the URL on the command line it was expanded from
+}
+
+// run fetches g, expanded from the URL at index i on the command line. Its
+// progress is shown as part of batch when -Z runs it alongside others.
+func run(ctx context.Context, o *Options, s *session, g globURL, i int, batch *batchProgress) error {
+	method := o.RequestMethod()
+	if !ValidMethod(method) {
+		return fmt.Errorf("invalid request method %q", method)
+	}
+
+	u, err := ParseURL(g.url)
+	if err != nil {
+		return err
+	}
+
+	data, err := o.RequestData()
+	if err != nil {
+		return err
+	}
+	var body []byte
+	var contentType string
+	if len(o.Data) > 0 {
+		if o.Get {
+			if u.RawQuery != "" {
+				u.RawQuery += "&"
+			}
+			u.RawQuery += data
+		} else {
+			body, contentType = []byte(data), "application/x-www-form-urlencoded"
+			if o.isJSON() {
+				contentType = "application/json"
+			}
+		}
+	}
+	appendQuery(u, o.Queries)
+	if len(o.Forms) > 0 {
+		if body != nil {
+			return errors.New("-F cannot be combined with -d")
+		}
+		if body, contentType, err = buildForm(o.Forms); err != nil {
+			return err
+		}
+	}
+
+	if o.Upload != "" {
+		u = uploadURL(u, o.Upload)
+	}
+
+	output, err := outputName(o, u, i, g.matches)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+
+	t := &transfer{Options: o, jar: s.jar, netrc: s.netrc, pool: s.pool, tracer: s.tracer, doh: s.doh, body: body, contentType: contentType, output: output, progress: o.showProgress(output), batch: batch}
+	if o.PathAsIs {
+		t.rawPath = rawPath(g.url)
+	}
+	if o.NoBuffer || o.HeadBytes > 0 {
+		t.stream = &bodyStream{t: t}
+		defer t.stream.close()
+	}
+	if err := t.setupConn(); err != nil {
+		return err
+	}
+	if o.Upload != "" {
+		if err := t.prepareUpload(); err != nil {
+			return err
+		}
+	}
+	if o.RequestTarget != "" && !validTarget(o.RequestTarget) {
+		return fmt.Errorf("invalid --request-target %q: it must be a single token with no spaces", o.RequestTarget)
+	}
+	if o.ByteRange != "" && !validRange(o.ByteRange) {
+		return fmt.Errorf("invalid --range %q", o.ByteRange)
+	}
+	if o.ContinueAt != "" {
+		if t.resumeFrom, err = resumeOffset(o.ContinueAt, output); err != nil {
+			return err
+		}
+	}
+	if o.EtagCompare != "" {
+		t.ifNoneMatch = readETag(o.EtagCompare)
+	}
+	if o.TimeCond != "" {
+		var ok bool
+		if t.timeCond, ok = parseTimeCond(o.TimeCond); !ok {
+			o.warnf("Illegal date format for -z, --time-cond (and not a file name). Disabling time condition.")
+		}
+	}
+	if err := t.setupLimits(); err != nil {
+		return err
+	}
+
+	if o.DryRun {
+		return t.dryRun(method, u)
+	}
+	if method == "CONNECT" {
+		if o.MaxTime > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, seconds(o.MaxTime))
+			defer cancel()
+		}
+		if err := t.connectTunnel(ctx, u); err != nil {
+			if errors.Is(ctx.Err(), context.Canceled) {
+				return ErrInterrupted
+			}
+			if ctx.Err() == context.DeadlineExceeded {
+				return exitErrorf(exitTimeout, "Operation timed out after %d ms", time.Since(start).Milliseconds())
+			}
+			return err
+		}
+		return nil
+	}
+	hops, err := t.perform(ctx, method, u)
+	if err != nil {
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return ErrInterrupted
+		}
+		return err
+	}
+	if err := s.headerDump.write(hops); err != nil {
+		return err
+	}
+	if err := s.headersJSON.write(hops); err != nil {
+		return err
+	}
+	final := hops[len(hops)-1]
+	switch {
+	case t.notModified(final):
+		if !o.Silent {
+			o.infof("Not modified on the server; the output was left as it is")
+		}
+	case final.StatusCode >= 400 && o.Fail:
+		err = failError(final.StatusCode)
+	case final.StatusCode >= 400 && o.FailWithBody:
+		if err = t.writeResponse(hops); err == nil {
+			err = failError(final.StatusCode)
+		}
+	default:
+		err = t.writeResponse(hops)
+	}
+	if err == nil && o.EtagSave != "" && final.StatusCode/100 == 2 {
+		err = saveETag(o.EtagSave, final)
+	}
+
+	if o.WriteOut != "" {
+		info := &transferInfo{hops: hops, total: time.Since(start), insecure: o.Insecure}
+		fmt.Fprint(os.Stdout, expandWriteOut(o, o.WriteOut, info))
+	}
+	return err
+}
+
+// setupConn sets t up to connect the way its options say: TLS, the proxy,
+// the resolver, and the addresses and ports to connect to and from.
+func (t *transfer) setupConn() error {
+	var err error
+	if t.tlsConfig, err = newTLSConfig(t.Options); err != nil {
+		return err
+	}
+	if t.Proxy != "" {
+		if t.proxy, err = parseProxy(t.Proxy); err != nil {
+			return err
+		}
+	}
+	if t.resolver, err = newResolver(t.DNSServers); err != nil {
+		return err
+	}
+	if t.Interface != "" {
+		if t.localIPs, err = interfaceAddrs(t.Interface, t.network()); err != nil {
+			return err
+		}
+	}
+	if t.LocalPort != "" {
+		if t.localPorts, err = parseLocalPort(t.LocalPort); err != nil {
+			return err
+		}
+	}
+	for _, arg := range t.Resolve {
+		e, err := parseResolve(arg)
+		if err != nil {
+			return err
+		}
+		t.resolve = append(t.resolve, e)
+	}
+	for _, arg := range t.ConnectTo {
+		e, err := parseConnectTo(arg)
+		if err != nil {
+			return err
+		}
+		t.connectTo = append(t.connectTo, e)
+	}
+	return nil
+}
+
+// setupLimits sets the limits the options put on the response.
+func (t *transfer) setupLimits() error {
+	var err error
+	if t.MaxFilesize != "" {
+		if t.maxFilesize, err = parseSize(t.MaxFilesize); err != nil {
+			return fmt.Errorf("invalid --max-filesize %q", t.MaxFilesize)
+		}
+	}
+	if t.maxHeadersSize, err = parseSize(t.MaxHeadersSize); err != nil {
+		return fmt.Errorf("invalid --max-headers-size %q", t.MaxHeadersSize)
+	}
+	if t.LimitRate != "" {
+		if t.rateLimit, err = parseSize(t.LimitRate); err != nil || t.rateLimit == 0 {
+			return fmt.Errorf("invalid --limit-rate %q", t.LimitRate)
+		}
+	}
+	return nil
+}
+
+// ValidMethod reports whether method is a valid HTTP method token: one or
+// more token characters as defined by RFC 9110, so no spaces or separators.
+func ValidMethod(method string) bool {
+	if method == "" {
+		return false
+	}
+	for _, r := range method {
+		if r > unicode.MaxASCII || unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, r) {
+			return false
+		}
+	}
+	return true
+}
+
+// Report prints err to stderr, or the --stderr file, the way curl reports
+// a failure, unless -s silenced it, and returns the exit status it calls
+// for.
+func (o *Options) Report(err error) int {
+	exitErr := Classify(err)
+	if exitErr.Err != nil && o.showErrors() {
+		fmt.Fprintf(o.stderr(), "%s: (%d) %v\n", progName, exitErr.Code, exitErr.Err)
+	}
+	return exitErr.Code
+}
+
+// connSettings sums up the options that decide how a connection is made,
+// so that a connection made for one request is only reused by a later one
+// that would have made it the same way.
+func (o *Options) connSettings() string {
+	return fmt.Sprint(o.Insecure, o.CACert, o.Cert, o.Key, o.TLSv10, o.TLSv11, o.TLSv12, o.TLSv13, o.TLSMax, o.SNI, o.ALPN, o.NoALPN,
+		o.Resolve, o.ConnectTo, o.IPv4, o.IPv6, o.UnixSocket, o.AbstractSocket, o.Interface, o.LocalPort,
+		o.Proxy, o.DoHURL, o.DNSServers, o.HTTP10, o.HTTP2)
+}
@@ -0,0 +1,9 @@
+package curl
+
+// abstractSocketAddr returns the address to dial for the socket called name
+// in the abstract namespace, which a leading NUL byte sets apart from a path.
+// The net package writes that byte for a leading @, which also reads better
+// in error messages.
+func abstractSocketAddr(name string) (string, error) {
+	return "@" + name, nil
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package curl
+
+// abstractSocketAddr fails, as the abstract socket namespace is Linux only.
+func abstractSocketAddr(name string) (string, error) {
+	return "", exitErrorf(exitUnsupported, "--abstract-unix-socket is only supported on Linux")
+}
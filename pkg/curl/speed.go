@@ -0,0 +1,111 @@
+package curl
+
+import (
+	"io"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSpeedLimit = 1  // bytes per second for --speed-time alone
+	defaultSpeedTime  = 30 // seconds for --speed-limit alone
+)
+
+// speedCheck aborts a transfer that stays slower than limit bytes per
+// second, counting both directions, for seconds in a row: it samples the
+// bytes moved once a second and, when the last window of samples falls
+// short, cuts the connection off.
+type speedCheck struct {
+	limit   int64
+	seconds int
+	moved   atomic.Int64
+	tripped atomic.Bool
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// startSpeedCheck starts watching conn for the --speed-limit and
+// --speed-time of o, or returns nil if neither is set. All speedCheck
+// methods accept a nil receiver.
+func startSpeedCheck(o *Options, conn net.Conn) *speedCheck {
+	if o.SpeedLimit <= 0 && o.SpeedTime <= 0 {
+		return nil
+	}
+	s := &speedCheck{limit: o.SpeedLimit, seconds: o.SpeedTime, done: make(chan struct{})}
+	if s.limit <= 0 {
+		s.limit = defaultSpeedLimit
+	}
+	if s.seconds <= 0 {
+		s.seconds = defaultSpeedTime
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		samples := []int64{0}
+		for {
+			select {
+			case <-ticker.C:
+			case <-s.done:
+				return
+			}
+			samples = append(samples, s.moved.Load())
+			if len(samples) <= s.seconds {
+				continue
+			}
+			samples = samples[len(samples)-s.seconds-1:]
+			if samples[s.seconds]-samples[0] < s.limit*int64(s.seconds) {
+				s.tripped.Store(true)
+				conn.SetDeadline(time.Now())
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// wrap returns rw with the bytes read and written through it counted.
+func (s *speedCheck) wrap(rw io.ReadWriter) io.ReadWriter {
+	if s == nil {
+		return rw
+	}
+	return &countingConn{rw: rw, n: &s.moved}
+}
+
+// stop ends the watch. It returns the error to report in place of the one
+// the transfer failed with, if the connection was cut off for being too
+// slow.
+func (s *speedCheck) stop() error {
+	if s == nil {
+		return nil
+	}
+	close(s.done)
+	s.wg.Wait()
+	if s.tripped.Load() {
+		return exitErrorf(exitTimeout, "Operation too slow. Less than %d bytes/sec transferred the last %d seconds", s.limit, s.seconds)
+	}
+	return nil
+}
+
+// countingConn adds the bytes read and written through it to n.
+type countingConn struct {
+	rw io.ReadWriter
+	n  *atomic.Int64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.rw.Read(b)
+	c.n.Add(int64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.rw.Write(b)
+	c.n.Add(int64(n))
+	return n, err
+}
@@ -0,0 +1,461 @@
+package curl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// bodyStream writes the output out as the response is read, for -N, rather
+// than once the transfer is complete. It owns the output for the whole
+// transfer: with -i the header block of each hop goes out as soon as it is
+// in, and the body of the final response follows piece by piece, stripped
+// of its chunked framing and decoded as writeResponse would. For
+// --head-bytes it stops the body after that many bytes.
+type bodyStream struct {
+	t   *transfer
+	out io.Writer // the output, once opened
+	f   *os.File  // the output file, or nil for stdout
+
+	created  bool // whether f was created or truncated, rather than appended to
+	finished bool // whether finish ran, so that the output is whole
+
+	// The state of the response being read, reset by start.
+	req      *request
+	decided  bool           // whether its header block is in
+	active   bool           // whether its body is being written out
+	complete bool           // whether all of its body has been written
+	offset   int            // of the first raw byte not yet handled
+	length   int64          // body bytes still to come, or -1 until the end
+	chunks   *chunkParser   // framing of a chunked body, or nil
+	decoder  *streamDecoder // undoes the content coding, or nil
+	body     io.Writer      // where the body goes after decoding, if it does
+	written  *countWriter   // counts what of the body has been written out
+	cut      bool           // whether the body was cut short by --head-bytes
+}
+
+// start gets s ready for the response to req. A nil stream, for transfers
+// without -N, does nothing here or in any of the other methods.
+func (s *bodyStream) start(req *request) {
+	if s == nil {
+		return
+	}
+	*s = bodyStream{t: s.t, out: s.out, f: s.f, created: s.created, req: req, length: -1}
+}
+
+// feed handles raw, the response read so far, and reports whether the
+// whole body has been written out, which is only known for a response that
+// is being streamed.
+func (s *bodyStream) feed(raw []byte) (complete bool, err error) {
+	if s == nil || s.complete {
+		return s != nil && s.complete, nil
+	}
+	if !s.decided {
+		head, body := splitResponse(raw)
+		if body == nil {
+			return false, nil
+		}
+		s.decided = true
+		s.offset = len(raw) - len(body)
+		if err := s.begin(head); err != nil {
+			return false, err
+		}
+	}
+	if !s.active {
+		return false, nil
+	}
+
+	p := raw[s.offset:]
+	s.offset = len(raw)
+	switch {
+	case s.chunks != nil:
+		emit := s.write
+		if s.t.Raw {
+			// Follow the framing only to find the end, and write it out
+			// along with the data.
+			emit = func([]byte) error { return nil }
+		}
+		var exit *ExitError
+		if s.complete, err = s.chunks.feed(p, emit); err != nil && !errors.As(err, &exit) {
+			err = exitErrorf(exitRecv, "Problem with the chunked encoding: %w", err)
+		}
+		if err == nil && s.t.Raw {
+			err = s.write(p)
+		}
+	case s.length >= 0:
+		p = p[:min(int64(len(p)), s.length)]
+		s.length -= int64(len(p))
+		s.complete = s.length == 0
+		err = s.write(p)
+	default:
+		err = s.write(p)
+	}
+	if errors.Is(err, errHeadBytes) {
+		s.complete, s.cut, err = true, true, nil
+	}
+	return s.complete, err
+}
+
+// truncated reports whether the body of the response was cut short by
+// --head-bytes, and so was not read to its end.
+func (s *bodyStream) truncated() bool {
+	return s != nil && s.cut
+}
+
+// streaming reports whether the body of the response is being written out,
+// in which case only feed knows when it is complete.
+func (s *bodyStream) streaming() bool {
+	return s != nil && s.active
+}
+
+// begin decides, from its header block head, what of the response goes
+// out. Nothing does for a response -f fails on, and only the headers, with
+// -i, for one that is followed by another request or has no body.
+func (s *bodyStream) begin(head []byte) error {
+	t := s.t
+	code, _ := strconv.Atoi(string(statusCode(head)))
+	if code >= 400 && t.Fail {
+		return nil
+	}
+	if code == http.StatusNotModified && t.conditional() {
+		// The output is left as it is.
+		return nil
+	}
+	if t.Include || t.Head {
+		if err := s.open(code); err != nil {
+			return err
+		}
+		if err := s.write(head); err != nil {
+			return err
+		}
+	}
+
+	location, _ := headerValue(head, "Location")
+	switch {
+	case t.Location && isRedirect(code) && location != "":
+		return nil
+	case code == http.StatusUnauthorized && s.req.answersDigest:
+		return nil
+	case s.req.method == "HEAD" || bodilessStatus(head):
+		return nil
+	}
+
+	if t.resumeFrom > 0 && code == http.StatusPartialContent {
+		cr, _ := headerValue(head, "Content-Range")
+		if err := checkContentRange(cr, t.resumeFrom); err != nil {
+			return err
+		}
+	} else if t.resumeFrom > 0 && t.Verbose {
+		t.infof("Server ignored the range request; restarting the download from the beginning")
+	}
+	if err := s.open(code); err != nil {
+		return err
+	}
+	s.active = true
+	s.body = s.out
+	if t.HeadBytes > 0 {
+		s.body = &limitWriter{w: s.out, left: t.HeadBytes}
+	}
+	s.written = &countWriter{w: s.body}
+	s.body = s.written
+	if isChunked(head) {
+		s.chunks = &chunkParser{}
+	} else if cl, ok := headerValue(head, "Content-Length"); ok && !t.IgnoreContentLength {
+		if n, err := strconv.ParseInt(cl, 10, 64); err == nil && n >= 0 {
+			s.length = n
+		}
+	}
+	if s.length == 0 {
+		s.complete = true
+	}
+	var codings []string
+	if te, _ := headerValue(head, "Transfer-Encoding"); !t.Raw {
+		codings = transferCodings(te)
+		if err := checkTransferCodings(codings); err != nil {
+			return err
+		}
+	}
+	if encoding, _ := headerValue(head, "Content-Encoding"); encoding != "" && t.decodes(encoding) {
+		codings = append(codings, encoding)
+	}
+	if len(codings) > 0 {
+		s.decoder = newStreamDecoder(codings, s.body)
+	}
+	return nil
+}
+
+// open opens the output, unless it already is, for a response with status
+// code. The file is appended to with -a, or for a download resumed with a
+// 206 response.
+func (s *bodyStream) open(code int) error {
+	if s.out != nil {
+		return nil
+	}
+	t := s.t
+	if t.output == "" {
+		s.out = os.Stdout
+		return nil
+	}
+	if err := t.createOutputDirs(); err != nil {
+		return err
+	}
+	appendTo := t.AppendOutput || (t.resumeFrom > 0 && code == http.StatusPartialContent)
+	f, err := openOutput(t.output, appendTo)
+	if err != nil {
+		return err
+	}
+	s.out, s.f, s.created = f, f, !appendTo
+	return nil
+}
+
+// write sends p, a piece of the output, on through the decoder if there is
+// one.
+func (s *bodyStream) write(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	if s.decoder != nil {
+		if _, err := s.decoder.pw.Write(p); err != nil {
+			return s.decoder.wait()
+		}
+		return nil
+	}
+	w := s.out
+	if s.body != nil {
+		w = s.body
+	}
+	if _, err := w.Write(p); err != nil {
+		if errors.Is(err, errHeadBytes) {
+			return err
+		}
+		return writeError(err)
+	}
+	return nil
+}
+
+// end finishes the response being read, waiting for the decoder to write
+// out what is left.
+func (s *bodyStream) end() error {
+	if s == nil || s.decoder == nil {
+		return nil
+	}
+	s.decoder.pw.Close()
+	err := s.decoder.wait()
+	s.decoder = nil
+	if errors.Is(err, errHeadBytes) {
+		s.cut, err = true, nil
+	}
+	return err
+}
+
+// finish opens the output if nothing was written to it, so that -o still
+// creates the file for an empty body, and closes it. With -R the file then
+// takes the Last-Modified time of resp.
+func (s *bodyStream) finish(resp *Response) error {
+	s.finished = true
+	if err := s.open(resp.StatusCode); err != nil {
+		return err
+	}
+	if s.written != nil {
+		resp.decodedSize = s.written.n
+	}
+	if s.f == nil {
+		return nil
+	}
+	err := s.f.Close()
+	s.f = nil
+	if err != nil {
+		return writeError(err)
+	}
+	if s.t.RemoteTime {
+		setRemoteTime(s.t.output, resp.header("Last-Modified"))
+	}
+	return nil
+}
+
+// close closes the output file if finish did not. The transfer then
+// failed or was cancelled, so a file it created holds only part of the
+// body and is removed; one appended to is left for a later -C - to resume.
+func (s *bodyStream) close() {
+	if s == nil || s.f == nil {
+		return
+	}
+	s.f.Close()
+	if !s.finished && s.created {
+		os.Remove(s.t.output)
+	}
+}
+
+// streamDecoder undoes transfer and content codings on the data written to
+// pw, one after the other, writing the result out from a goroutine of its
+// own.
+type streamDecoder struct {
+	codings []string
+	pw      *io.PipeWriter
+	done    chan error
+	err     error
+}
+
+func newStreamDecoder(codings []string, out io.Writer) *streamDecoder {
+	pr, pw := io.Pipe()
+	d := &streamDecoder{codings: codings, pw: pw, done: make(chan error, 1)}
+	go func() {
+		var r io.Reader = pr
+		var err error
+		for _, coding := range codings {
+			var cr io.Reader
+			if cr, err = contentReader(coding, r); err != nil {
+				break
+			}
+			if cr != nil {
+				r = cr
+			}
+		}
+		if err == nil {
+			_, err = io.Copy(outputWriter{out}, r)
+		}
+		pr.CloseWithError(err)
+		d.done <- err
+	}()
+	return d
+}
+
+// wait returns once the decoder is done, with its error, if any.
+func (d *streamDecoder) wait() error {
+	if d.done != nil {
+		d.err = <-d.done
+		d.done = nil
+	}
+	var exit *ExitError
+	if d.err == nil || errors.As(d.err, &exit) || errors.Is(d.err, errHeadBytes) {
+		return d.err
+	}
+	return exitErrorf(exitBadEncoding, "Error while processing content unencoding: %s: %w", strings.Join(d.codings, ", "), d.err)
+}
+
+// errHeadBytes stops the body once --head-bytes of it have been written.
+var errHeadBytes = errors.New("--head-bytes reached")
+
+// limitWriter writes on to w until left bytes have been written, and then
+// fails with errHeadBytes.
+type limitWriter struct {
+	w    io.Writer
+	left int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) < l.left {
+		n, err := l.w.Write(p)
+		l.left -= int64(n)
+		return n, err
+	}
+	n, err := l.w.Write(p[:l.left])
+	l.left -= int64(n)
+	if err == nil {
+		err = errHeadBytes
+	}
+	return n, err
+}
+
+// countWriter writes on to w, counting the bytes written.
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// outputWriter reports the errors of writing to w as failures to write the
+// output.
+type outputWriter struct {
+	w io.Writer
+}
+
+func (o outputWriter) Write(p []byte) (int, error) {
+	n, err := o.w.Write(p)
+	if err != nil && !errors.Is(err, errHeadBytes) {
+		err = writeError(err)
+	}
+	return n, err
+}
+
+// writeError reports err, from writing the output, with curl's exit code.
+func writeError(err error) error {
+	return exitErrorf(exitWrite, "Failure writing output to destination: %w", err)
+}
+
+// chunkParser strips the chunked framing from a body fed to it piece by
+// piece, as it arrives.
+type chunkParser struct {
+	state chunkState
+	line  []byte // the part of a size or trailer line read so far
+	left  int64  // bytes of the current chunk still to come
+}
+
+type chunkState int
+
+const (
+	chunkSize    chunkState = iota // reading a chunk size line
+	chunkData                      // reading chunk data
+	chunkEnd                       // reading the CRLF after chunk data
+	chunkTrailer                   // reading the trailer after the last chunk
+	chunkDone
+)
+
+// feed parses p, passing the chunk data in it to emit, and reports whether
+// the last chunk and the trailer after it have been read.
+func (c *chunkParser) feed(p []byte, emit func([]byte) error) (done bool, err error) {
+	for len(p) > 0 && c.state != chunkDone {
+		if c.state == chunkData {
+			n := min(int64(len(p)), c.left)
+			if err := emit(p[:n]); err != nil {
+				return false, err
+			}
+			p, c.left = p[n:], c.left-n
+			if c.left == 0 {
+				c.state = chunkEnd
+			}
+			continue
+		}
+
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			c.line = append(c.line, p...)
+			break
+		}
+		line := strings.TrimRight(string(c.line)+string(p[:i]), "\r")
+		c.line, p = c.line[:0], p[i+1:]
+		switch c.state {
+		case chunkSize:
+			sizeField, _, _ := strings.Cut(line, ";") // drop chunk extensions
+			size, err := strconv.ParseInt(strings.TrimSpace(sizeField), 16, 64)
+			if err != nil || size < 0 {
+				return false, fmt.Errorf("invalid chunk size line %q", line)
+			}
+			if size == 0 {
+				c.state = chunkTrailer
+			} else {
+				c.state, c.left = chunkData, size
+			}
+		case chunkEnd:
+			if line != "" {
+				return false, errors.New("malformed chunked encoding: missing CRLF after chunk data")
+			}
+			c.state = chunkSize
+		case chunkTrailer:
+			if line == "" {
+				c.state = chunkDone
+			}
+		}
+	}
+	return c.state == chunkDone, nil
+}
@@ -0,0 +1,63 @@
+package curl
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStreamCancelRemovesPartialOutput(t *testing.T) {
+	wrote := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1000")
+		w.Write(make([]byte, 100))
+		w.(http.Flusher).Flush()
+		close(wrote)
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "part.out")
+	o := DefaultOptions()
+	o.NoBuffer, o.Silent, o.Output = true, true, []string{out}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-wrote
+		// Give the client a moment to write what it got to the file.
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	var c Client
+	defer c.CloseIdleConnections()
+	err := c.Run(ctx, &o, []string{srv.URL})
+	if code := Classify(err).Code; code != exitInterrupted {
+		t.Errorf("exit code = %d (%v), want %d", code, err, exitInterrupted)
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("the partial -o file was left behind (stat: %v)", err)
+	}
+}
+
+func TestStreamKeepsCompleteOutput(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("whole"))
+	}))
+	defer srv.Close()
+
+	out := filepath.Join(t.TempDir(), "whole.out")
+	o := DefaultOptions()
+	o.NoBuffer, o.Silent, o.Output = true, true, []string{out}
+	var c Client
+	defer c.CloseIdleConnections()
+	if err := c.Run(context.Background(), &o, []string{srv.URL}); err != nil {
+		t.Fatal(err)
+	}
+	if b, err := os.ReadFile(out); err != nil || string(b) != "whole" {
+		t.Errorf("-o file = %q, %v; want \"whole\"", b, err)
+	}
+}
@@ -0,0 +1,38 @@
+package curl
+
+import (
+	"io"
+	"time"
+)
+
+// hopTiming records how long the stages of one request took to complete,
+// each measured from the start of the request, for the -w time_ variables.
+type hopTiming struct {
+	start         time.Time
+	namelookup    time.Duration // host name resolved
+	connect       time.Duration // TCP connection established
+	appconnect    time.Duration // TLS handshake done
+	starttransfer time.Duration // first response byte received
+}
+
+// mark records the time elapsed since the start of the request in d.
+func (h *hopTiming) mark(d *time.Duration) {
+	*d = time.Since(h.start)
+}
+
+// firstByteReader marks the start of the transfer in timing when the first
+// byte of the response is read.
+type firstByteReader struct {
+	r      io.Reader
+	timing *hopTiming
+	seen   bool
+}
+
+func (f *firstByteReader) Read(b []byte) (int, error) {
+	n, err := f.r.Read(b)
+	if n > 0 && !f.seen {
+		f.seen = true
+		f.timing.mark(&f.timing.starttransfer)
+	}
+	return n, err
+}
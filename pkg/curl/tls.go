@@ -0,0 +1,121 @@
+package curl
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/net/http2"
+)
+
+// certTimeFormat is how -v prints certificate validity dates.
+const certTimeFormat = "Jan _2 15:04:05 2006 GMT"
+
+// tlsVersions maps the TLS version names used by --tls-max to their
+// crypto/tls constants.
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// newTLSConfig builds the TLS configuration shared by every https
+// connection of a transfer. The server name is filled in per connection.
+func newTLSConfig(o *Options) (*tls.Config, error) {
+	config := &tls.Config{InsecureSkipVerify: o.Insecure, MinVersion: o.tlsMin()}
+	switch {
+	case o.NoALPN:
+	case o.ALPN != "":
+		for _, proto := range strings.Split(o.ALPN, ",") {
+			if proto = strings.TrimSpace(proto); proto == "" {
+				return nil, fmt.Errorf("invalid --alpn %q: expected a comma-separated list of protocols", o.ALPN)
+			}
+			config.NextProtos = append(config.NextProtos, proto)
+		}
+	case o.HTTP2:
+		config.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	default:
+		config.NextProtos = []string{"http/1.1"}
+	}
+	if o.TLSMax != "" {
+		v, ok := tlsVersions[o.TLSMax]
+		if !ok {
+			return nil, fmt.Errorf("invalid --tls-max %q: expected 1.0, 1.1, 1.2 or 1.3", o.TLSMax)
+		}
+		if config.MinVersion > v {
+			return nil, fmt.Errorf("--tls-max %s is below the minimum TLS version requested", o.TLSMax)
+		}
+		config.MaxVersion = v
+	}
+	if o.CACert != "" {
+		pool, err := loadCertPool(o.CACert)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = pool
+	}
+	if o.Cert != "" {
+		cert, err := loadClientCert(o.Cert, o.Key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+// loadClientCert loads the client certificate in certFile with its private
+// key from keyFile, or from certFile too when keyFile is empty.
+func loadClientCert(certFile, keyFile string) (tls.Certificate, error) {
+	if keyFile == "" {
+		keyFile = certFile
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, exitErrorf(exitClientCert, "could not load client key pair from %s and %s: %w", certFile, keyFile, err)
+	}
+	return cert, nil
+}
+
+// loadCertPool reads the PEM CA bundle in the file called name. The bundle
+// replaces the system roots rather than adding to them.
+func loadCertPool(name string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(name)
+	if err != nil {
+		return nil, exitErrorf(exitCACert, "error setting certificate file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, exitErrorf(exitCACert, "error setting certificate file: no certificates found in %s", name)
+	}
+	return pool, nil
+}
+
+// dumpTLS prints the negotiated TLS parameters and the server certificate
+// for -v.
+func (o *Options) dumpTLS(state tls.ConnectionState) {
+	o.infof("SSL connection using %s / %s", tls.VersionName(state.Version), tls.CipherSuiteName(state.CipherSuite))
+	if !o.NoALPN {
+		if state.NegotiatedProtocol != "" {
+			o.infof("ALPN: server accepted %s", state.NegotiatedProtocol)
+		} else {
+			o.infof("ALPN: server did not agree on a protocol. Uses default.")
+		}
+	}
+	if len(state.PeerCertificates) > 0 {
+		cert := state.PeerCertificates[0]
+		o.infof("Server certificate:")
+		o.infof(" subject: %s", cert.Subject)
+		o.infof(" start date: %s", cert.NotBefore.UTC().Format(certTimeFormat))
+		o.infof(" expire date: %s", cert.NotAfter.UTC().Format(certTimeFormat))
+		o.infof(" issuer: %s", cert.Issuer)
+	}
+	if o.Insecure {
+		o.infof(" SSL certificate verification skipped (--insecure)")
+	} else {
+		o.infof(" SSL certificate verify ok.")
+	}
+}
@@ -0,0 +1,121 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// tracer writes every byte sent and received to the --trace or
+// --trace-ascii file, in curl's layout: a "=> Send" or "<= Recv" line per
+// read or write, followed by the data at offsets from its start. It is
+// shared by the transfers that -Z runs at the same time.
+type tracer struct {
+	mu    sync.Mutex
+	w     io.Writer
+	f     *os.File // the file behind w, or nil for stderr
+	ascii bool     // whether to leave out the hex dump
+}
+
+// openTracer creates the trace file called name, or traces to stderr for
+// "-".
+func openTracer(name string, ascii bool, stderr io.Writer) (*tracer, error) {
+	if name == "-" {
+		return &tracer{w: stderr, ascii: ascii}, nil
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, exitErrorf(exitWrite, "Failed to create the trace file %s: %w", name, err)
+	}
+	return &tracer{w: f, f: f, ascii: ascii}, nil
+}
+
+func (tr *tracer) close() {
+	if tr.f != nil {
+		tr.f.Close()
+	}
+}
+
+// wrap returns rw with its traffic traced, or rw itself for a nil tracer.
+// Over TLS rw is the *tls.Conn, so the plaintext is traced rather than the
+// handshake and records.
+func (tr *tracer) wrap(rw io.ReadWriter) io.ReadWriter {
+	if tr == nil {
+		return rw
+	}
+	return &tracedConn{rw: rw, tr: tr}
+}
+
+// tracedConn traces what is read and written through it.
+type tracedConn struct {
+	rw io.ReadWriter
+	tr *tracer
+}
+
+func (c *tracedConn) Read(b []byte) (int, error) {
+	n, err := c.rw.Read(b)
+	if n > 0 {
+		c.tr.dump("<= Recv data", b[:n])
+	}
+	return n, err
+}
+
+func (c *tracedConn) Write(b []byte) (int, error) {
+	n, err := c.rw.Write(b)
+	if n > 0 {
+		c.tr.dump("=> Send data", b[:n])
+	}
+	return n, err
+}
+
+// dump writes data under a heading of what it is. The hex layout shows 16
+// bytes a line next to their printable characters; the ASCII one shows up
+// to 64 characters a line, breaking lines after each CRLF.
+func (tr *tracer) dump(what string, data []byte) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%s, %d bytes (0x%x)\n", what, len(data), len(data))
+	width := 16
+	if tr.ascii {
+		width = 64
+	}
+	for off := 0; off < len(data); {
+		end := min(off+width, len(data))
+		if tr.ascii {
+			if i := bytes.Index(data[off:end], []byte("\r\n")); i >= 0 {
+				end = off + i + 2
+			} else if end < len(data) && data[end-1] == '\r' && data[end] == '\n' {
+				end++
+			}
+		}
+		line := data[off:end]
+		if tr.ascii {
+			line = bytes.TrimSuffix(line, []byte("\r\n"))
+		}
+
+		fmt.Fprintf(&b, "%04x: ", off)
+		if !tr.ascii {
+			for i := range width {
+				if i < len(line) {
+					fmt.Fprintf(&b, "%02x ", line[i])
+				} else {
+					b.WriteString("   ")
+				}
+			}
+		}
+		for _, c := range line {
+			if c >= 0x20 && c < 0x7f {
+				b.WriteByte(c)
+			} else {
+				b.WriteByte('.')
+			}
+		}
+		b.WriteByte('\n')
+		off = end
+	}
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+	tr.w.Write(b.Bytes())
+}
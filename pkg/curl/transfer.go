@@ -0,0 +1,351 @@
+package curl
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// transfer is the work of fetching one URL, shared by every hop made while
+// following its redirects.
+type transfer struct {
+	*Options
+	jar            *cookieJar
+	pool           *connPool      // idle connections to reuse, or nil
+	tracer         *tracer        // --trace destination, or nil
+	doh            *dohResolver   // resolver for --doh-url, or nil
+	resolver       *net.Resolver  // resolver for --dns-servers, or nil for the system one
+	netrc          netrc          // credentials from -n or --netrc-file, or nil
+	body           []byte         // request body from -d or -F, or nil for none
+	contentType    string         // Content-Type of body
+	output         string         // file the body is saved to, or "" for stdout
+	rawPath        string         // path of the URL as written, for --path-as-is
+	progress       bool           // whether to show the progress meter
+	batch          *batchProgress // combined meter for -Z, or nil
+	stream         *bodyStream    // writes the output as it arrives for -N, or nil
+	rateLimit      int64          // maximum download speed in bytes per second, or 0
+	maxFilesize    int64          // largest response body accepted, or 0 for any
+	maxHeadersSize int64          // largest response header block accepted, or 0 for any
+	resumeFrom     int64          // offset to resume a download at with -C, or 0
+	ifNoneMatch    string         // entity tag from --etag-compare, or ""
+	timeCond       header         // If-Modified-Since or If-Unmodified-Since header for -z, if any
+	tlsConfig      *tls.Config
+	resolve        []resolveEntry
+	connectTo      []connectToEntry
+	localIPs       []netip.Addr // addresses of the --interface to bind to, or nil
+	localPorts     portRange    // --local-port range to bind to
+	uploadSize     int64        // size of the -T body
+	uploadData     []byte       // the -T body when read from stdin
+	uploadType     string       // Content-Type of the -T body
+	uploadChunked  bool         // whether stdin is streamed in chunked encoding instead
+	stdinSent      bool         // whether the chunked upload has been sent
+	proxy          *url.URL     // -x proxy, or nil to connect directly
+}
+
+// follow requests u, following redirects when -L is set. It returns every
+// response received, ending with the final one.
+func (t *transfer) follow(ctx context.Context, method string, u *url.URL) ([]*Response, error) {
+	var hops []*Response
+	h := t.firstHop(method, u)
+	_, autoReferer := strings.CutSuffix(t.Referer, ";auto")
+	for redirects := 0; ; redirects++ {
+		req, err := newRequest(t, h)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := t.send(ctx, req, h)
+		if err != nil {
+			return nil, err
+		}
+		hops = append(hops, resp)
+		if t.cookieEngine() {
+			t.jar.store(resp, h.url)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && t.answersDigest(h) {
+			challenge, err := parseDigestChallenge(resp.Headers["Www-Authenticate"])
+			if err != nil {
+				return nil, err
+			}
+			if h.authorization, err = challenge.authorize(t.User, h.method, req.target); err != nil {
+				return nil, err
+			}
+			redirects-- // answering the challenge is not a redirect
+			continue
+		}
+
+		location := resp.header("Location")
+		if !t.Location || !isRedirect(resp.StatusCode) || location == "" {
+			return hops, nil
+		}
+		if t.MaxRedirs >= 0 && redirects == t.MaxRedirs {
+			return nil, exitErrorf(exitTooManyRedirects, "Maximum (%d) redirects followed", t.MaxRedirs)
+		}
+
+		ref, err := url.Parse(escapeURL(location))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Location header %q: %w", location, err)
+		}
+		next := &hop{url: h.url.ResolveReference(ref), referer: h.referer}
+		next.crossHost = !t.LocationTrusted && (h.crossHost || !sameHost(next.url, u))
+		next.method, next.body = redirectMethod(resp.StatusCode, h.method, h.body, t.keepMethod(resp.StatusCode))
+		next.upload = h.upload && next.method == h.method
+		if autoReferer {
+			next.referer = h.url.String()
+		}
+		h = next
+		if t.Verbose {
+			t.infof("Issue another request to this URL: '%s'", h.url)
+		}
+	}
+}
+
+// firstHop returns the hop that starts a transfer of u with method.
+func (t *transfer) firstHop(method string, u *url.URL) *hop {
+	referer, _ := strings.CutSuffix(t.Referer, ";auto")
+	return &hop{method: method, url: u, rawPath: t.rawPath, body: t.body, referer: referer, upload: t.Upload != ""}
+}
+
+// send makes the round trip for hop h, streaming the -T file as the body if
+// the hop sends it.
+func (t *transfer) send(ctx context.Context, req *request, h *hop) (*Response, error) {
+	if h.upload {
+		f, err := t.openUpload()
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		req.upload = f
+	}
+	return t.roundTrip(ctx, req, h.url)
+}
+
+// roundTrip sends req to the host named by u and reads back the response,
+// over an idle connection from the pool if there is one and over a new one
+// otherwise. The connection goes back to the pool afterwards if it can be
+// reused. The deadline of ctx, if any, also bounds reads and writes.
+func (t *transfer) roundTrip(ctx context.Context, req *request, u *url.URL) (*Response, error) {
+	key := poolKey(u)
+	if req.upload == nil {
+		// A streamed body could not be sent again, should the idle
+		// connection turn out to have been closed by the server.
+		for conn := t.pool.get(key); conn != nil; conn = t.pool.get(key) {
+			if t.Verbose {
+				t.infof("Re-using existing connection with host %s", u.Hostname())
+			}
+			resp, err := t.exchange(ctx, conn, true, req, u, hopTiming{start: time.Now()})
+			if err != errStaleConn {
+				return resp, err
+			}
+			if t.Verbose {
+				t.infof("Connection died, retrying with a fresh connection")
+			}
+		}
+	}
+
+	timing := hopTiming{start: time.Now()}
+	conn, err := dial(ctx, t, u, &timing)
+	if err != nil {
+		return nil, err
+	}
+	return t.exchange(ctx, conn, false, req, u, timing)
+}
+
+// errStaleConn reports that a reused connection was closed by the server
+// before it answered.
+var errStaleConn = errors.New("connection closed before the response")
+
+// exchange sends req over conn and reads back the response. conn is closed
+// afterwards, unless it goes back to the pool. A reused connection that
+// yields no response at all fails with errStaleConn.
+func (t *transfer) exchange(ctx context.Context, conn net.Conn, reused bool, req *request, u *url.URL, timing hopTiming) (*Response, error) {
+	kept := false
+	defer func() {
+		if !kept {
+			conn.Close()
+		}
+	}()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	// Reads and writes don't watch ctx, so cut them short when it is
+	// cancelled.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	h2 := isH2(conn)
+	if h2 {
+		toH2(req)
+	}
+
+	if t.Verbose {
+		if !reused {
+			t.dumpConn(conn, u)
+		}
+		t.dumpLines("> ", req.head())
+	}
+
+	t.stream.start(req)
+	meter := t.startProgress()
+	if req.upload != nil {
+		req.upload = meter.uploading(req.upload, t.uploadSize)
+	}
+
+	speed := startSpeedCheck(t.Options, conn)
+	rw := speed.wrap(t.tracer.wrap(conn))
+	var r io.Reader = &firstByteReader{r: rw, timing: &timing}
+	if t.rateLimit > 0 {
+		r = newRateLimitedReader(ctx, r, t.rateLimit)
+	}
+
+	var raw []byte
+	var err error
+	if h2 {
+		raw, err = h2RoundTrip(r, rw, req, u, t.maxHeadersSize, meter, t.stream)
+	} else if req.expectsContinue() {
+		raw, err = t.sendExpecting(ctx, conn, rw, r, req, meter)
+	} else if err = req.write(rw); err == nil {
+		raw, err = t.readResponse(r, req.method, meter)
+	}
+	meter.stop()
+	if end := t.stream.end(); err == nil {
+		err = end
+	}
+	if slow := speed.stop(); slow != nil {
+		return nil, slow
+	}
+	if reused && len(raw) == 0 {
+		return nil, errStaleConn
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	parse := parseResponse
+	if t.stream.truncated() {
+		parse = parsePartialResponse
+	}
+	resp, err := parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	te := strings.Join(resp.Headers["Transfer-Encoding"], ", ")
+	if t.IgnoreContentLength && te == "" {
+		// Everything up to the end of the connection is the body.
+		_, resp.Body = splitResponse(raw)
+	}
+	if t.maxFilesize > 0 && int64(len(resp.Body)) > t.maxFilesize {
+		return nil, errFileSize
+	}
+	if t.Raw && te != "" {
+		// Keep the chunked framing and any other transfer coding in the
+		// body.
+		_, resp.Body = splitResponse(raw)
+	} else if codings := transferCodings(te); len(codings) > 0 && !t.stream.truncated() {
+		if err := checkTransferCodings(codings); err != nil {
+			return nil, err
+		}
+		if resp.Body, err = decodeCodings(codings, resp.Body); err != nil {
+			return nil, err
+		}
+	}
+	resp.url = u
+	resp.timing = timing
+	if t.Verbose {
+		t.dumpLines("< ", resp.rawInterim)
+		t.dumpLines("< ", resp.rawHeader)
+		t.dumpLines("< ", resp.rawTrailer)
+	}
+	if t.pool != nil && !h2 && !t.IgnoreContentLength && keepAlive(req, raw, resp) && stop() {
+		t.pool.put(poolKey(u), conn)
+		kept = true
+	}
+	return resp, nil
+}
+
+// dumpConn prints where conn, newly made for a request to u, is connected
+// to for -v, along with the TLS session if there is one.
+func (t *transfer) dumpConn(conn net.Conn, u *url.URL) {
+	name := u.Hostname()
+	if t.proxy != nil {
+		name = t.proxy.Hostname()
+	}
+	switch addr := conn.RemoteAddr().(type) {
+	case *net.TCPAddr:
+		t.infof("Connected to %s (%s) port %d", name, addr.IP, addr.Port)
+	case *net.UnixAddr:
+		if t.AbstractSocket != "" {
+			t.infof("Connected to %s via abstract unix socket %s", u.Hostname(), t.AbstractSocket)
+		} else {
+			t.infof("Connected to %s via unix socket %s", u.Hostname(), t.UnixSocket)
+		}
+	}
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		t.dumpTLS(tlsConn.ConnectionState())
+	}
+}
+
+// writeResponse decodes the body of the final response in hops as asked,
+// re-indents it for --pretty if it is JSON, and writes it to the output
+// file, or to stdout when there is none. With -i or -I the headers of every
+// hop are written ahead of the body. With -N, where the output was written
+// as it arrived, it just finishes the output off.
+func (t *transfer) writeResponse(hops []*Response) error {
+	resp := hops[len(hops)-1]
+	if t.stream != nil {
+		// -N has written it all out already.
+		return t.stream.finish(resp)
+	}
+	body := resp.Body
+	if t.decodes(resp.header("Content-Encoding")) {
+		var err error
+		if body, err = decodeContent(resp.header("Content-Encoding"), body); err != nil {
+			return err
+		}
+	}
+	resp.decodedSize = int64(len(body))
+	if t.Pretty && isJSONType(resp.header("Content-Type")) {
+		if pretty, ok := prettyJSON(body); ok {
+			body = pretty
+		} else {
+			t.warnf("--pretty: the response body is not valid JSON; writing it as it is")
+		}
+	}
+
+	if t.Include || t.Head {
+		var out []byte
+		for _, hop := range hops {
+			out = append(out, hop.rawHeader...)
+		}
+		body = append(out, body...)
+	}
+	appendTo := t.AppendOutput
+	if t.resumeFrom > 0 && resp.StatusCode == http.StatusPartialContent {
+		if err := checkContentRange(resp.header("Content-Range"), t.resumeFrom); err != nil {
+			return err
+		}
+		appendTo = true
+	} else if t.resumeFrom > 0 && t.Verbose {
+		t.infof("Server ignored the range request; restarting the download from the beginning")
+	}
+	if err := t.createOutputDirs(); err != nil {
+		return err
+	}
+	if err := writeBody(t.output, body, appendTo); err != nil {
+		return err
+	}
+	if t.RemoteTime && t.output != "" {
+		setRemoteTime(t.output, resp.header("Last-Modified"))
+	}
+	return nil
+}
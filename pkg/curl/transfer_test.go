@@ -0,0 +1,70 @@
+package curl
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/textproto"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// exchangeRaw sends a GET for u over a pipe to a server that answers with
+// raw and then closes the connection, and returns what exchange makes of it.
+func exchangeRaw(t *testing.T, o *Options, raw string) (*Response, error) {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		// Read the request head, then answer.
+		tp := textproto.NewReader(bufio.NewReader(server))
+		if _, err := tp.ReadLine(); err != nil {
+			return
+		}
+		if _, err := tp.ReadMIMEHeader(); err != nil {
+			return
+		}
+		server.Write([]byte(raw))
+	}()
+
+	u, err := url.Parse("http://h/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &transfer{Options: o, jar: &cookieJar{}}
+	req, err := newRequest(tr, tr.firstHop("GET", u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return tr.exchange(ctx, client, false, req, u, hopTiming{start: time.Now()})
+}
+
+func TestExchangeIgnoreContentLength(t *testing.T) {
+	const body = "the whole body, up to the close"
+	tests := []struct {
+		name   string
+		length int
+		ignore bool
+		want   string
+	}{
+		{"declared larger", len(body) + 100, true, body},
+		{"declared smaller", 3, true, body},
+		{"declared smaller without the flag", 3, false, body[:3]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := fmt.Sprintf("HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", tt.length, body)
+			resp, err := exchangeRaw(t, &Options{IgnoreContentLength: tt.ignore}, raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(resp.Body) != tt.want {
+				t.Errorf("body %q, want %q", resp.Body, tt.want)
+			}
+		})
+	}
+}
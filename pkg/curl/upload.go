@@ -0,0 +1,95 @@
+package curl
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// uploadURL returns u with the base name of the -T file appended when the
+// URL names a directory, as curl does.
+func uploadURL(u *url.URL, file string) *url.URL {
+	if file == "-" || (u.Path != "" && !strings.HasSuffix(u.Path, "/")) {
+		return u
+	}
+	v := *u
+	v.Path = path.Join("/", u.Path, filepath.Base(file))
+	return &v
+}
+
+// prepareUpload checks the -T file and records its size for Content-Length
+// and its type for Content-Type. Stdin cannot be sized up front, so "-" is
+// streamed in chunked encoding over HTTP/1.1, with no type, or read into
+// memory with --no-chunked or HTTP/1.0.
+func (t *transfer) prepareUpload() error {
+	if t.Upload == "-" && !t.NoChunked && t.proto() == "HTTP/1.1" {
+		t.uploadChunked = true
+		return nil
+	}
+	if t.Upload == "-" {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return exitErrorf(exitRead, "Failed to read data from stdin: %w", err)
+		}
+		t.uploadData, t.uploadSize = b, int64(len(b))
+		t.uploadType = http.DetectContentType(b)
+		return nil
+	}
+
+	fi, err := os.Stat(t.Upload)
+	if err != nil {
+		return exitErrorf(exitRead, "Can't open '%s': %w", t.Upload, err)
+	}
+	if fi.IsDir() {
+		return exitErrorf(exitRead, "Can't upload '%s': is a directory", t.Upload)
+	}
+	t.uploadSize = fi.Size()
+	t.uploadType, err = uploadType(t.Upload)
+	return err
+}
+
+// uploadType returns the media type of the file called name, from its
+// extension or else from sniffing its first 512 bytes.
+func uploadType(name string) (string, error) {
+	if typ := mime.TypeByExtension(filepath.Ext(name)); typ != "" {
+		return typ, nil
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return "", exitErrorf(exitRead, "Can't open '%s': %w", name, err)
+	}
+	defer f.Close()
+	b := make([]byte, 512)
+	n, err := io.ReadFull(f, b)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", exitErrorf(exitRead, "Failed to read '%s': %w", name, err)
+	}
+	return http.DetectContentType(b[:n]), nil
+}
+
+// openUpload opens the -T body for one request. The caller closes it. A
+// chunked upload from stdin can only be sent once; a redirect, retry or
+// authentication challenge that calls for it again fails.
+func (t *transfer) openUpload() (io.ReadCloser, error) {
+	if t.uploadData != nil {
+		return io.NopCloser(bytes.NewReader(t.uploadData)), nil
+	}
+	if t.uploadChunked {
+		if t.stdinSent {
+			return nil, exitErrorf(exitSendRewind, "Can't send the upload from stdin again; use --no-chunked to keep it in memory")
+		}
+		t.stdinSent = true
+		return io.NopCloser(os.Stdin), nil
+	}
+	f, err := os.Open(t.Upload)
+	if err != nil {
+		return nil, exitErrorf(exitRead, "Can't open '%s': %w", t.Upload, err)
+	}
+	return f, nil
+}
@@ -0,0 +1,181 @@
+package curl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ParseURL parses the URL given on the command line as curl does: one
+// without a scheme is taken to be http, and only http and https URLs with a
+// host are accepted.
+func ParseURL(raw string) (*url.URL, error) {
+	if schemeLen(raw) == 0 {
+		raw = "http://" + raw
+	}
+	u, err := url.Parse(escapeURL(raw))
+	if err != nil {
+		return nil, exitErrorf(exitURLMalformat, "URL using bad/illegal format: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+	case "unix":
+		return nil, exitErrorf(exitUnsupported, "Protocol \"unix\" not supported; use --unix-socket with an http URL")
+	default:
+		return nil, exitErrorf(exitUnsupported, "Protocol \"%s\" not supported", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, exitErrorf(exitURLMalformat, "No host part in the URL %s", raw)
+	}
+	return u, nil
+}
+
+// schemeLen returns the length of the "scheme://" that s starts with, or 0
+// if it has none. A "://" further on, such as in the query, does not count.
+func schemeLen(s string) int {
+	i := strings.Index(s, "://")
+	if i <= 0 || !isAlpha(s[0]) {
+		return 0
+	}
+	for j := 1; j < i; j++ {
+		if c := s[j]; !isAlpha(c) && !('0' <= c && c <= '9') && c != '+' && c != '-' && c != '.' {
+			return 0
+		}
+	}
+	return i + 3
+}
+
+// isAlpha reports whether c is an ASCII letter.
+func isAlpha(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+// escapeURL percent-encodes the bytes in the path, query and fragment of
+// ref, a URL or relative reference as written, that are not allowed there:
+// spaces, control characters, bytes outside ASCII and a few others such as
+// quotes and brackets. Like curl, it fixes these up rather than sending a
+// malformed request line. A % that starts a %XX escape is left as it is,
+// and any other one is encoded.
+func escapeURL(ref string) string {
+	start := schemeLen(ref)
+	if start == 0 && strings.HasPrefix(ref, "//") {
+		start = 2
+	}
+	if start > 0 {
+		if end := strings.IndexAny(ref[start:], "/?#"); end >= 0 {
+			start += end
+		} else {
+			return ref
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(ref[:start])
+	for i := start; i < len(ref); i++ {
+		c := ref[i]
+		switch {
+		case c == '%' && i+2 < len(ref) && isHex(ref[i+1]) && isHex(ref[i+2]):
+			b.WriteByte(c)
+		case c <= ' ' || c >= 0x7f || strings.IndexByte("%\"<>\\^`{|}", c) >= 0:
+			fmt.Fprintf(&b, "%%%02X", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// isHex reports whether c is a hexadecimal digit.
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// appendQuery adds the --query pairs, given as "name=value" or just
+// "name", to the query string of u, after any it already has and in the
+// order given. Both the name and the value are percent-encoded.
+func appendQuery(u *url.URL, pairs []string) {
+	for _, pair := range pairs {
+		name, value, hasValue := strings.Cut(pair, "=")
+		field := queryEscape(name)
+		if hasValue {
+			field += "=" + queryEscape(value)
+		}
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += field
+	}
+}
+
+// queryEscape percent-encodes s for a query string, spaces included.
+func queryEscape(s string) string {
+	return strings.ReplaceAll(url.QueryEscape(s), "+", "%20")
+}
+
+// requestTarget returns the origin-form request target for u: its path, with
+// dot segments resolved and "/" for none, followed by the query if there is
+// one. The fragment is for the client alone and never sent.
+func requestTarget(u *url.URL) string {
+	path := removeDotSegments(u.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+	return withQuery(path, u)
+}
+
+// withQuery appends the query of u, if any, to path.
+func withQuery(path string, u *url.URL) string {
+	if u.RawQuery == "" && !u.ForceQuery {
+		return path
+	}
+	return path + "?" + u.RawQuery
+}
+
+// rawPath returns the path of the URL arg exactly as it was written, without
+// the query or fragment, for --path-as-is.
+func rawPath(arg string) string {
+	arg = arg[schemeLen(arg):]
+	i := strings.IndexAny(arg, "/?#")
+	if i < 0 || arg[i] != '/' {
+		return ""
+	}
+	p := arg[i:]
+	if j := strings.IndexAny(p, "?#"); j >= 0 {
+		p = p[:j]
+	}
+	return p
+}
+
+// removeDotSegments resolves the "." and ".." segments of path as RFC 3986
+// section 5.2.4 describes, the way curl normalizes the path unless
+// --path-as-is is set. Empty segments, as in "//", are kept.
+func removeDotSegments(path string) string {
+	if !strings.Contains(path, ".") {
+		return path
+	}
+	var out []string
+	segments := strings.Split(path, "/")
+	for i, seg := range segments {
+		last := i == len(segments)-1
+		switch seg {
+		case ".":
+			if last {
+				out = append(out, "")
+			}
+		case "..":
+			if len(out) > 1 || (len(out) == 1 && out[0] != "") {
+				out = out[:len(out)-1]
+			}
+			if last {
+				out = append(out, "")
+			}
+		default:
+			out = append(out, seg)
+		}
+	}
+	cleaned := strings.Join(out, "/")
+	if strings.HasPrefix(path, "/") && !strings.HasPrefix(cleaned, "/") {
+		cleaned = "/" + cleaned
+	}
+	return cleaned
+}
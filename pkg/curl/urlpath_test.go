@@ -0,0 +1,139 @@
+package curl
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseURL(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string // the URL parsed, or "" when it is rejected
+		code int    // exit code of the rejection
+	}{
+		{raw: "example.com", want: "http://example.com"},
+		{raw: "example.com:8080/a", want: "http://example.com:8080/a"},
+		{raw: "127.0.0.1:8080/echo?next=http://x", want: "http://127.0.0.1:8080/echo?next=http://x"},
+		{raw: "example.com?u=a://b", want: "http://example.com?u=a://b"},
+		{raw: "https://example.com/a", want: "https://example.com/a"},
+		{raw: "HTTP://example.com", want: "http://example.com"},
+		{raw: "ftp://example.com/f", code: exitUnsupported},
+		{raw: "unix:///run/x.sock", code: exitUnsupported},
+		{raw: "http:///a", code: exitURLMalformat},
+		{raw: "http://", code: exitURLMalformat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			u, err := ParseURL(tt.raw)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("ParseURL(%q) = %s, want an error", tt.raw, u)
+				}
+				if code := Classify(err).Code; code != tt.code {
+					t.Errorf("ParseURL(%q) exit code = %d, want %d", tt.raw, code, tt.code)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseURL(%q): %v", tt.raw, err)
+			}
+			if got := u.String(); got != tt.want {
+				t.Errorf("ParseURL(%q) = %s, want %s", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSchemeLen(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int
+	}{
+		{"http://h", 7},
+		{"svn+ssh://h", 10},
+		{"h", 0},
+		{"h/a?u=http://x", 0},
+		{"h?u=a://b", 0},
+		{"://h", 0},
+		{"1http://h", 0},
+	}
+	for _, tt := range tests {
+		if got := schemeLen(tt.s); got != tt.want {
+			t.Errorf("schemeLen(%q) = %d, want %d", tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestRequestTarget(t *testing.T) {
+	tests := []struct {
+		url, want string
+	}{
+		{"http://h", "/"},
+		{"http://h/", "/"},
+		{"http://h?q=1", "/?q=1"},
+		{"http://h/a/b", "/a/b"},
+		{"http://h/a?x=1&y=2", "/a?x=1&y=2"},
+		{"http://h/a?", "/a?"},
+		{"http://h/a#frag", "/a"},
+		{"http://h/a?x=1#frag", "/a?x=1"},
+		{"http://h/a/./b/../c", "/a/c"},
+		{"http://h/a%20b", "/a%20b"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := requestTarget(u); got != tt.want {
+			t.Errorf("requestTarget(%s) = %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeURL(t *testing.T) {
+	tests := []struct {
+		ref, want string
+	}{
+		{"http://h/a b", "http://h/a%20b"},
+		{"http://h/a?q=x y", "http://h/a?q=x%20y"},
+		{"http://h/café", "http://h/caf%C3%A9"},
+		{"http://h/%41%2f", "http://h/%41%2f"},
+		{"http://h/%zz", "http://h/%25zz"},
+		{"http://h/100%", "http://h/100%25"},
+		{`http://h/"a"<b>`, "http://h/%22a%22%3Cb%3E"},
+		{"http://h/a#x y", "http://h/a#x%20y"},
+		{"http://us er@h/", "http://us er@h/"},
+		{"http://h", "http://h"},
+		{"/rel path", "/rel%20path"},
+		{"//h/a b", "//h/a%20b"},
+	}
+	for _, tt := range tests {
+		if got := escapeURL(tt.ref); got != tt.want {
+			t.Errorf("escapeURL(%q) = %q, want %q", tt.ref, got, tt.want)
+		}
+	}
+}
+
+func TestPathAsIsSkipsEscaping(t *testing.T) {
+	const arg = "http://h/a b/%zz/../c?q=1"
+	u, err := ParseURL(arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := &transfer{Options: &Options{PathAsIs: true, UserAgent: defaultUserAgent}, jar: &cookieJar{}, rawPath: rawPath(arg)}
+	req, err := newRequest(tr, tr.firstHop("GET", u))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/a b/%zz/../c?q=1"; req.target != want {
+		t.Errorf("--path-as-is target = %q, want %q", req.target, want)
+	}
+
+	tr.rawPath = ""
+	if req, err = newRequest(tr, tr.firstHop("GET", u)); err != nil {
+		t.Fatal(err)
+	}
+	if want := "/a%20b/c?q=1"; req.target != want {
+		t.Errorf("target = %q, want %q", req.target, want)
+	}
+}
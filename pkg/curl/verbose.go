@@ -0,0 +1,29 @@
+package curl
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// infof writes a "* " informational line to stderr, or the --stderr file.
+func (o *Options) infof(format string, args ...any) {
+	fmt.Fprintf(o.stderr(), "* "+format+"\n", args...)
+}
+
+// warnf writes a warning like infof unless -s silenced it.
+func (o *Options) warnf(format string, args ...any) {
+	if o.showErrors() {
+		fmt.Fprintf(o.stderr(), "Warning: "+format+"\n", args...)
+	}
+}
+
+// dumpLines writes each CRLF-terminated line of block like infof, behind
+// prefix, the way -v shows request and response headers.
+func (o *Options) dumpLines(prefix string, block []byte) {
+	for _, line := range bytes.SplitAfter(block, []byte("\r\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		fmt.Fprintf(o.stderr(), "%s%s\n", prefix, bytes.TrimRight(line, "\r\n"))
+	}
+}
@@ -0,0 +1,187 @@
+package curl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// transferInfo records what -w can report about a finished transfer.
+type transferInfo struct {
+	hops     []*Response // every response received, ending with the final one
+	total    time.Duration
+	insecure bool // whether -k skipped verifying the server certificate
+}
+
+// writeOutVar returns the value of the -w variable called name, and whether
+// the variable is known.
+func (t *transferInfo) writeOutVar(name string) (string, bool) {
+	final := t.hops[len(t.hops)-1]
+	switch name {
+	case "http_code", "response_code":
+		return fmt.Sprintf("%03d", final.StatusCode), true
+	case "size_download":
+		return strconv.FormatInt(sizeDownload(final), 10), true
+	case "size_download_raw":
+		return strconv.FormatInt(final.wireSize, 10), true
+	case "speed_download":
+		return strconv.FormatInt(t.speedDownload(), 10), true
+	case "content_type":
+		return final.header("Content-Type"), true
+	case "time_namelookup":
+		return formatSeconds(final.timing.namelookup), true
+	case "time_connect":
+		return formatSeconds(final.timing.connect), true
+	case "time_appconnect":
+		return formatSeconds(final.timing.appconnect), true
+	case "time_starttransfer":
+		return formatSeconds(final.timing.starttransfer), true
+	case "time_total":
+		return formatSeconds(t.total), true
+	case "num_redirects":
+		return strconv.Itoa(t.numRedirects()), true
+	case "url_effective":
+		return final.url.String(), true
+	case "http_version":
+		return strings.TrimPrefix(final.Proto, "HTTP/"), true
+	case "scheme":
+		return final.url.Scheme, true
+	case "ssl_verify_result":
+		return strconv.Itoa(t.sslVerifyResult()), true
+	case "json":
+		return t.json(), true
+	}
+	return "", false
+}
+
+// sizeDownload returns the size of the body of resp as written out, once
+// decoded, or as received when it was not written.
+func sizeDownload(resp *Response) int64 {
+	if resp.decodedSize >= 0 {
+		return resp.decodedSize
+	}
+	return int64(len(resp.Body))
+}
+
+// speedDownload returns the average speed, in bytes per second, at which
+// the body of the final response came over the connection.
+func (t *transferInfo) speedDownload() int64 {
+	if t.total <= 0 {
+		return 0
+	}
+	return int64(float64(t.hops[len(t.hops)-1].wireSize) / t.total.Seconds())
+}
+
+// numRedirects counts the redirects followed on the way to the final
+// response.
+func (t *transferInfo) numRedirects() int {
+	n := 0
+	for _, hop := range t.hops[:len(t.hops)-1] {
+		if isRedirect(hop.StatusCode) {
+			n++
+		}
+	}
+	return n
+}
+
+// sslVerifyResult is 0 when the certificate of the server was verified, or
+// there was none, and 1 when -k left it unchecked.
+func (t *transferInfo) sslVerifyResult() int {
+	if t.insecure && t.hops[len(t.hops)-1].url.Scheme == "https" {
+		return 1
+	}
+	return 0
+}
+
+// writeOutJSON holds every other -w variable, for %{json}. Numbers are
+// written as numbers, with times in seconds as the variables print them.
+type writeOutJSON struct {
+	ContentType       string      `json:"content_type"`
+	HTTPCode          int         `json:"http_code"`
+	HTTPVersion       string      `json:"http_version"`
+	NumRedirects      int         `json:"num_redirects"`
+	ResponseCode      int         `json:"response_code"`
+	Scheme            string      `json:"scheme"`
+	SizeDownload      int64       `json:"size_download"`
+	SizeDownloadRaw   int64       `json:"size_download_raw"`
+	SpeedDownload     int64       `json:"speed_download"`
+	SSLVerifyResult   int         `json:"ssl_verify_result"`
+	TimeAppconnect    json.Number `json:"time_appconnect"`
+	TimeConnect       json.Number `json:"time_connect"`
+	TimeNamelookup    json.Number `json:"time_namelookup"`
+	TimeStarttransfer json.Number `json:"time_starttransfer"`
+	TimeTotal         json.Number `json:"time_total"`
+	URLEffective      string      `json:"url_effective"`
+}
+
+// json renders the variables of t as a JSON object.
+func (t *transferInfo) json() string {
+	final := t.hops[len(t.hops)-1]
+	out, _ := json.Marshal(writeOutJSON{
+		ContentType:       final.header("Content-Type"),
+		HTTPCode:          final.StatusCode,
+		HTTPVersion:       strings.TrimPrefix(final.Proto, "HTTP/"),
+		NumRedirects:      t.numRedirects(),
+		ResponseCode:      final.StatusCode,
+		Scheme:            final.url.Scheme,
+		SizeDownload:      sizeDownload(final),
+		SizeDownloadRaw:   final.wireSize,
+		SpeedDownload:     t.speedDownload(),
+		SSLVerifyResult:   t.sslVerifyResult(),
+		TimeAppconnect:    json.Number(formatSeconds(final.timing.appconnect)),
+		TimeConnect:       json.Number(formatSeconds(final.timing.connect)),
+		TimeNamelookup:    json.Number(formatSeconds(final.timing.namelookup)),
+		TimeStarttransfer: json.Number(formatSeconds(final.timing.starttransfer)),
+		TimeTotal:         json.Number(formatSeconds(t.total)),
+		URLEffective:      final.url.String(),
+	})
+	return string(out)
+}
+
+// formatSeconds renders d in seconds with microsecond precision, as -w
+// prints times.
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.6f", d.Seconds())
+}
+
+// writeOutEscapes maps the backslash escapes allowed in -w to the bytes
+// they stand for.
+var writeOutEscapes = map[byte]byte{'n': '\n', 'r': '\r', 't': '\t', '\\': '\\'}
+
+// expandWriteOut expands the -w template format: %{name} is replaced by the
+// variable's value, %% by a literal percent and \n, \r and \t by the
+// characters they name. Unknown variables are warned about and left in
+// place.
+func expandWriteOut(o *Options, format string, t *transferInfo) string {
+	var b strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		switch {
+		case c == '%' && strings.HasPrefix(format[i:], "%%"):
+			b.WriteByte('%')
+			i++
+		case c == '%' && strings.HasPrefix(format[i:], "%{"):
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				b.WriteString(format[i:])
+				return b.String()
+			}
+			name := format[i+2 : i+end]
+			if value, ok := t.writeOutVar(name); ok {
+				b.WriteString(value)
+			} else {
+				o.warnf("unknown --write-out variable: '%s'", name)
+				b.WriteString(format[i : i+end+1])
+			}
+			i += end
+		case c == '\\' && i+1 < len(format) && writeOutEscapes[format[i+1]] != 0:
+			b.WriteByte(writeOutEscapes[format[i+1]])
+			i++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}